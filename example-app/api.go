@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/davecgh/go-spew/spew"
 
@@ -10,13 +12,14 @@ import (
 
 func init() {
 	rest.AddTypeToRegistry(Message{})
+	rest.AddTypeToRegistry(DocumentMeta{})
 }
 
 type Module struct {
 }
 
 type Message struct {
-	Message     string                 `json:"message"`
+	Message     string                 `json:"message" validate:"required,max=280"`
 	Sender      Sender                 `json:"sender"`
 	ListExample []string               `json:"listExample"`
 	MapExample  map[string]interface{} `json:"mapExample"`
@@ -24,34 +27,61 @@ type Message struct {
 
 type Sender struct {
 	ID       int32  `json:"id"`
-	Name     string `json:"name"`
+	Name     string `json:"name" validate:"required,min=3"`
 	Verified bool   `json:"verified"`
 }
 
+type DocumentMeta struct {
+	Name  string   `form:"name" validate:"required"`
+	Pages int      `form:"pages" validate:"min=0"`
+	Tags  []string `form:"tag"`
+}
+
 func NewModule() *Module {
 	return &Module{}
 }
 
-func (b *Module) ListMessages(headers map[string]string) ([]string, error) {
-	fmt.Println("List messages called")
-	spew.Dump(headers)
+func (b *Module) ListMessages(headers map[string]string, limit int, tags []string) ([]string, error) {
+	fmt.Printf("List messages called (limit %d)\n", limit)
+	spew.Dump(headers, tags)
 	return []string{"List", "messages", "called"}, nil
 }
 
-func (b *Module) GetMessage(id int) (string, error) {
+func (b *Module) GetMessage(ctx context.Context, id int) (string, error) {
+	if e := ctx.Err(); e != nil {
+		return "", e
+	}
+	if id <= 0 {
+		return "", rest.NotFound("message %d not found", id)
+	}
 	fmt.Printf("Get message %d called\n", id)
 	return fmt.Sprintf("Message %d\n", id), nil
 }
 
-func (b *Module) CreateMessage(m Message) (string, error) {
+func (b *Module) CreateMessage(m Message) (*rest.Response, error) {
 	fmt.Println("Create message called")
 	spew.Dump(m)
-	return "Message created", nil
+	return rest.Created("/api/messages/1", "Message created"), nil
 }
 
-func (b *Module) UploadDocument(f *rest.FileInfo, n string) (string, error) {
+func (b *Module) DeleteMessage(id int) (interface{}, error) {
+	fmt.Printf("Delete message %d called\n", id)
+	return nil, nil
+}
+
+func (b *Module) UploadDocument(f *rest.FileInfo, meta DocumentMeta) (string, error) {
 	fmt.Println("Upload document called")
-	spew.Dump(n)
-	spew.Dump(string(f.File))
+	spew.Dump(meta)
+
+	file, e := f.Open()
+	if e != nil {
+		return "", e
+	}
+	defer file.Close()
+	content, e := ioutil.ReadAll(file)
+	if e != nil {
+		return "", e
+	}
+	spew.Dump(f.SHA256, string(content))
 	return "Document uploaded", nil
 }
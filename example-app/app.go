@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/benschs/go-api/rest"
@@ -17,12 +19,20 @@ func main() {
 	controller := createRestfulController()
 	controller.Use(middleware.Recoverer)
 
-	cWriter := &CustomWriter{}
-	controller.SetWriter(cWriter)
+	controller.AddCodec(rest.MediaTypeJSON, &rest.JSONCodec{
+		Settings: rest.JSONSettings{UseIndent: true, Indent: "   "},
+	})
 
 	businessLogicImplementation := NewModule()
 	controller.AddModule(businessLogicImplementation)
 
+	if err := controller.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Changes to the routes file are applied without restart
+	controller.EnableHotReload(context.Background(), 0)
+
 	srv := http.Server{
 		Addr:    ADDRESS,
 		Handler: controller.Routes(),
@@ -35,7 +45,17 @@ func main() {
 func createRestfulController() *rest.Controller {
 
 	ctrl := rest.NewController()
-	ctrl.AddRequestConfigFromYAML(ROUTES_FILE)
+	ctrl.AddMiddleware("nocache", middleware.NoCache)
+	ctrl.SetAccessLog(true)
+	ctrl.SetMetrics(rest.MetricsSettings{Route: "/metrics"})
+	if err := ctrl.AddRequestConfigFromYAML(ROUTES_FILE); err != nil {
+		log.Fatal(err)
+	}
+	ctrl.SetOpenAPI(rest.OpenAPISettings{
+		Route:   "/api/openapi",
+		Title:   "Example API",
+		Version: "1.0.0",
+	})
 
 	return ctrl
 }
@@ -0,0 +1,133 @@
+// Package openapi contains the types of an OpenAPI 3.1 document.
+//
+// Only the parts of the specification that the rest package generates are modelled.
+// All types can be marshalled to JSON and YAML.
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version is the OpenAPI specification version of generated documents.
+const Version = "3.1.0"
+
+// Document is the root object of an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi" yaml:"openapi"`
+	Info       Info                 `json:"info" yaml:"info"`
+	Paths      map[string]*PathItem `json:"paths" yaml:"paths"`
+	Components *Components          `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+// Info provides metadata about the API.
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// PathItem describes the operations available on a single path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty" yaml:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty" yaml:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty" yaml:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty" yaml:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty" yaml:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty" yaml:"trace,omitempty"`
+}
+
+// SetOperation sets the operation for the provided HTTP method.
+// An error is returned if the method is unknown or already has an operation.
+func (p *PathItem) SetOperation(method string, op *Operation) error {
+	var target **Operation
+	switch strings.ToUpper(method) {
+	case "GET":
+		target = &p.Get
+	case "PUT":
+		target = &p.Put
+	case "POST":
+		target = &p.Post
+	case "DELETE":
+		target = &p.Delete
+	case "OPTIONS":
+		target = &p.Options
+	case "HEAD":
+		target = &p.Head
+	case "PATCH":
+		target = &p.Patch
+	case "TRACE":
+		target = &p.Trace
+	default:
+		return fmt.Errorf("unsupported HTTP method '%s'", method)
+	}
+
+	if *target != nil {
+		return fmt.Errorf("duplicate operation for method '%s'", method)
+	}
+	*target = op
+	return nil
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+// Parameter describes a single operation parameter.
+// In is one of "path", "query", "header" or "cookie".
+type Parameter struct {
+	Name        string  `json:"name" yaml:"name"`
+	In          string  `json:"in" yaml:"in"`
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// RequestBody describes a request body, keyed by media type.
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content" yaml:"content"`
+}
+
+// MediaType provides the schema of a request or response body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// Response describes a single response of an operation.
+type Response struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// Components holds reusable schemas referenced from the rest of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// Schema is a JSON Schema as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Default              interface{}        `json:"default,omitempty" yaml:"default,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+}
+
+// RefTo returns a schema referencing the named component schema.
+func RefTo(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// RouteBuilder declares a route in code, as an alternative to the configuration files, e.g.
+//
+//	c.Route("GET", "/messages/{id}").Param("id", rest.Int).Query("limit", rest.Int).Handle(mod.GetMessage)
+//
+// The route is added to the Requests of the Controller by Handle and checked like configured routes,
+// see Controller.Validate.
+type RouteBuilder struct {
+	c    *Controller
+	rqst Request
+}
+
+// Route starts the declaration of a route.
+func (c *Controller) Route(method, uri string) *RouteBuilder {
+	return &RouteBuilder{c: c, rqst: Request{Method: method, URI: uri}}
+}
+
+// Name sets the name of the route, the name of the handler method if not set.
+func (b *RouteBuilder) Name(name string) *RouteBuilder {
+	b.rqst.Name = name
+	return b
+}
+
+// Headers passes the headers to the method as map[string]string.
+func (b *RouteBuilder) Headers(names ...string) *RouteBuilder {
+	b.rqst.Headers = append(b.rqst.Headers, names...)
+	return b
+}
+
+// Param adds a URL parameter of the type.
+func (b *RouteBuilder) Param(name string, t ParamType) *RouteBuilder {
+	return b.URLParam(URLParam{Name: name, Type: t})
+}
+
+// URLParam adds a URL parameter with all settings.
+func (b *RouteBuilder) URLParam(p URLParam) *RouteBuilder {
+	b.rqst.Params = append(b.rqst.Params, p)
+	return b
+}
+
+// Query adds a query parameter of the type.
+func (b *RouteBuilder) Query(name string, t ParamType) *RouteBuilder {
+	return b.QueryParam(QueryParam{Name: name, Type: t})
+}
+
+// QueryParam adds a query parameter with all settings.
+func (b *RouteBuilder) QueryParam(p QueryParam) *RouteBuilder {
+	b.rqst.Query = append(b.rqst.Query, p)
+	return b
+}
+
+// QueryStruct binds the query parameters into a struct, see TypeName.
+func (b *RouteBuilder) QueryStruct(typeName string) *RouteBuilder {
+	b.rqst.QueryStruct = typeName
+	return b
+}
+
+// Body sets the body of the route, see JSONBody and MultipartBody.
+func (b *RouteBuilder) Body(body BodyType) *RouteBuilder {
+	b.rqst.Body = body
+	return b
+}
+
+// Consumes restricts the media types of the body.
+func (b *RouteBuilder) Consumes(mediaTypes ...string) *RouteBuilder {
+	b.rqst.Consumes = append(b.rqst.Consumes, mediaTypes...)
+	return b
+}
+
+// Produces restricts the media types of the response.
+func (b *RouteBuilder) Produces(mediaTypes ...string) *RouteBuilder {
+	b.rqst.Produces = append(b.rqst.Produces, mediaTypes...)
+	return b
+}
+
+// Status sets the status code of successful responses.
+func (b *RouteBuilder) Status(status int) *RouteBuilder {
+	b.rqst.Status = status
+	return b
+}
+
+// Middleware adds middleware by name, see Controller.AddMiddleware.
+func (b *RouteBuilder) Middleware(names ...string) *RouteBuilder {
+	b.rqst.Middleware = append(b.rqst.Middleware, names...)
+	return b
+}
+
+// Handle adds the route, handled by fn. fn is usually a method value of a module, e.g. mod.GetMessage,
+// and must follow the same rules as methods named in the configuration.
+func (b *RouteBuilder) Handle(fn interface{}) {
+	rqst := b.rqst
+	rqst.handler = reflect.ValueOf(fn)
+	_, rqst.Func = funcNames(rqst.handler)
+	if rqst.Name == "" {
+		rqst.Name = rqst.Func
+	}
+	b.c.mu.Lock()
+	b.c.Requests = append(b.c.Requests, rqst)
+	b.c.mu.Unlock()
+}
+
+// funcNames returns the receiver type and name of a method value, e.g. '*main.Module' and 'GetMessage'
+// for mod.GetMessage. The receiver of a function is its package. Closures are named after the function
+// declaring them, e.g. 'GetMessage.func1'.
+func funcNames(fn reflect.Value) (string, string) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return "", ""
+	}
+	// e.g. 'github.com/user/app/main.(*Module).GetMessage-fm' or 'github.com/user/app/main.Handler.func1'
+	full := strings.TrimSuffix(runtime.FuncForPC(fn.Pointer()).Name(), "-fm")
+	full = strings.ReplaceAll(full, "[...]", "")
+	full = full[strings.LastIndex(full, "/")+1:]
+	i := strings.Index(full, ".")
+	pkg, name := full[:i], full[i+1:]
+	if strings.HasPrefix(name, "(*") {
+		j := strings.Index(name, ").")
+		return "*" + pkg + "." + name[2:j], name[j+2:]
+	}
+	if j := strings.Index(name, "."); j >= 0 && !isClosureName(name[j+1:]) {
+		return pkg + "." + name[:j], name[j+1:]
+	}
+	return pkg, name
+}
+
+// isClosureName reports whether name is the name the compiler gives closures, e.g. 'func1'.
+func isClosureName(name string) bool {
+	return len(name) > 4 && strings.HasPrefix(name, "func") && name[4] >= '0' && name[4] <= '9'
+}
+
+// TypeName adds T to the TypeRegistry and returns its name.
+func TypeName[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	TypeRegistry[t.String()] = t
+	return t.String()
+}
+
+// JSONBody returns a body decoded into T.
+func JSONBody[T any]() BodyType {
+	return BodyType{IsJSON: true, JSONStructName: TypeName[T]()}
+}
+
+// MultipartBody returns a multipart body with the forms, see FileForm and FormStruct.
+func MultipartBody(forms ...MultipartForm) BodyType {
+	return BodyType{IsMultipart: true, Forms: forms}
+}
+
+// FileForm returns a form field passed as *FileInfo.
+func FileForm(name string) MultipartForm {
+	return MultipartForm{Name: name, IsFile: true}
+}
+
+// FormStruct returns the text fields of a multipart body bound into T.
+func FormStruct[T any]() MultipartForm {
+	return MultipartForm{StructName: TypeName[T]()}
+}
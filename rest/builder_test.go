@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type builderModule struct{}
+
+func (builderModule) Get(id int, limit int) (int, error) { return id * limit, nil }
+
+func (m *builderModule) Create(n builderNote) (*Response, error) {
+	return Created("/notes/1", n), nil
+}
+
+// Closure returns a closure declared in a method.
+func (m *builderModule) Closure() func() (string, error) {
+	return func() (string, error) { return "closure", nil }
+}
+
+type builderNote struct {
+	Text string `json:"text" validate:"required"`
+}
+
+func builderHealth() (string, error) { return "ok", nil }
+
+func builderGeneric[T any]() (T, error) {
+	var v T
+	return v, nil
+}
+
+func TestFuncNames(t *testing.T) {
+	m := &builderModule{}
+	closure := func() (string, error) { return "", nil }
+
+	tests := []struct {
+		name     string
+		fn       interface{}
+		receiver string
+		method   string
+	}{
+		{"function", builderHealth, "rest", "builderHealth"},
+		{"generic function", builderGeneric[int], "rest", "builderGeneric"},
+		{"value receiver", builderModule{}.Get, "rest.builderModule", "Get"},
+		{"pointer receiver", m.Create, "*rest.builderModule", "Create"},
+		{"method expression", (*builderModule).Create, "*rest.builderModule", "Create"},
+		{"closure", closure, "rest", "TestFuncNames.func1"},
+		{"closure in method", m.Closure(), "*rest.builderModule", "Closure.func1"},
+		{"nil", (func())(nil), "", ""},
+		{"no function", 1, "", ""},
+	}
+	for _, test := range tests {
+		receiver, method := funcNames(reflect.ValueOf(test.fn))
+		if receiver != test.receiver || method != test.method {
+			t.Errorf("%s: names %s, %s, want %s, %s", test.name, receiver, method, test.receiver, test.method)
+		}
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	name := TypeName[builderNote]()
+	if name != "rest.builderNote" || TypeRegistry[name] != reflect.TypeOf(builderNote{}) {
+		t.Errorf("name %s, type %v", name, TypeRegistry[name])
+	}
+	if name := TypeName[*builderNote](); name != "*rest.builderNote" || TypeRegistry[name] != reflect.TypeOf(&builderNote{}) {
+		t.Errorf("name %s, type %v", name, TypeRegistry[name])
+	}
+
+	body := JSONBody[builderNote]()
+	if !body.IsJSON || body.JSONStructName != "rest.builderNote" {
+		t.Errorf("body %+v", body)
+	}
+}
+
+func TestRouteBuilder(t *testing.T) {
+	m := &builderModule{}
+	c := NewController()
+	c.Route("GET", "/items/{id}").Param("id", Int).Query("limit", Int).Handle(builderModule{}.Get)
+	c.Route("POST", "/notes").Name("create note").Body(JSONBody[builderNote]()).Handle(m.Create)
+	c.Route("GET", "/health").Status(http.StatusAccepted).Handle(builderHealth)
+	c.Route("GET", "/closure").Handle(m.Closure())
+
+	if e := c.Validate(); e != nil {
+		t.Fatal(e)
+	}
+	names := make([]string, len(c.Requests))
+	for i, rqst := range c.Requests {
+		names[i] = rqst.Name + "=" + rqst.Func
+	}
+	if got := strings.Join(names, ","); got != "Get=Get,create note=Create,builderHealth=builderHealth,Closure.func1=Closure.func1" {
+		t.Errorf("routes %s", got)
+	}
+	if module := c.routeModule(c.Requests[1]); module != "*rest.builderModule" {
+		t.Errorf("module %s", module)
+	}
+
+	h := c.Routes()
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"GET", "/items/3?limit=2", "", http.StatusOK, "6\n"},
+		{"GET", "/items/3?limit=x", "", http.StatusBadRequest, ""},
+		{"POST", "/notes", `{"text":"a"}`, http.StatusCreated, "{\"text\":\"a\"}\n"},
+		{"POST", "/notes", `{"text":""}`, http.StatusUnprocessableEntity, ""},
+		{"GET", "/health", "", http.StatusAccepted, "\"ok\"\n"},
+		{"GET", "/closure", "", http.StatusOK, "\"closure\"\n"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", MediaTypeJSON)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status || test.want != "" && w.Body.String() != test.want {
+			t.Errorf("%s %s: status %d, body %q", test.method, test.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouteBuilderInvalidHandler(t *testing.T) {
+	c := NewController()
+	c.Route("GET", "/a").Handle(nil)
+	c.Route("GET", "/b").Handle(func(a, b int) {})
+	var ce *ConfigError
+	if e := c.Validate(); !errors.As(e, &ce) || len(ce.Routes) != 2 {
+		t.Errorf("error %v, want *ConfigError of both routes", e)
+	}
+}
@@ -0,0 +1,544 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Codec decodes request bodies and encodes response bodies of a media type.
+// Codecs are added to the Controller with AddCodec.
+type Codec interface {
+	// Decode reads the body into v, a pointer to a value of the registered body type.
+	Decode(r io.Reader, v interface{}) error
+	// Encode writes the result of a module method.
+	Encode(w io.Writer, v interface{}) error
+}
+
+// Media types of the built-in codecs.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeXML  = "application/xml"
+	MediaTypeYAML = "application/yaml"
+	MediaTypeCSV  = "text/csv"
+)
+
+// mediaCodec is a codec with the media type it is selected for.
+type mediaCodec struct {
+	mediaType string
+	codec     Codec
+
+	// optIn codecs are only used by routes listing their media type in consumes or produces
+	optIn bool
+}
+
+// AddCodec adds a codec for a media type, e.g. 'application/msgpack', or replaces the codec of the media type.
+//
+// Without per route settings, requests accept bodies and responses of all media types added with AddCodec.
+// If the Accept header of a request allows several media types equally, the type added first is used.
+//
+// NewController adds the JSON codec, and XML, YAML and CSV codecs that are only used by routes
+// listing their media type in consumes or produces, as they can not encode every result.
+// Replacing a codec keeps whether it is used by default.
+//
+// Binary formats like MessagePack or CBOR are not built in, to keep their libraries out of the dependencies.
+// They are added with a Codec wrapping the library, e.g. for github.com/vmihailenco/msgpack/v5:
+//
+//	type msgpackCodec struct{}
+//
+//	func (msgpackCodec) Decode(r io.Reader, v interface{}) error { return msgpack.NewDecoder(r).Decode(v) }
+//	func (msgpackCodec) Encode(w io.Writer, v interface{}) error { return msgpack.NewEncoder(w).Encode(v) }
+//
+//	c.AddCodec("application/msgpack", msgpackCodec{})
+func (c *Controller) AddCodec(mediaType string, codec Codec) {
+	c.addCodec(mediaType, codec, false)
+}
+
+func (c *Controller) addCodec(mediaType string, codec Codec, optIn bool) {
+	mediaType = strings.ToLower(mediaType)
+	for i, mc := range c.codecs {
+		if mc.mediaType == mediaType {
+			c.codecs[i].codec = codec
+			return
+		}
+	}
+	c.codecs = append(c.codecs, mediaCodec{mediaType: mediaType, codec: codec, optIn: optIn})
+}
+
+// routeCodecs returns the codecs of the media types, in order.
+// No media types select all codecs that are not opt-in.
+func (c *Controller) routeCodecs(mediaTypes []string) ([]mediaCodec, error) {
+	if len(mediaTypes) == 0 {
+		codecs := []mediaCodec{}
+		for _, mc := range c.codecs {
+			if !mc.optIn {
+				codecs = append(codecs, mc)
+			}
+		}
+		return codecs, nil
+	}
+
+	codecs := make([]mediaCodec, 0, len(mediaTypes))
+	for _, mt := range mediaTypes {
+		mc, ok := findCodec(c.codecs, strings.ToLower(mt))
+		if !ok {
+			return nil, fmt.Errorf("no codec for media type '%s'", mt)
+		}
+		codecs = append(codecs, mediaCodec{mediaType: mc.mediaType, codec: mc.codec})
+	}
+	return codecs, nil
+}
+
+// findCodec returns the codec of the media type. Media types with a structured syntax suffix,
+// e.g. 'application/vnd.api+json', fall back to the codec of the suffix.
+func findCodec(codecs []mediaCodec, mediaType string) (mediaCodec, bool) {
+	for _, mc := range codecs {
+		if mc.mediaType == mediaType {
+			return mc, true
+		}
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		suffix := mediaType[i+1:]
+		for _, mc := range codecs {
+			if strings.HasSuffix(mc.mediaType, "/"+suffix) {
+				return mc, true
+			}
+		}
+	}
+	return mediaCodec{}, false
+}
+
+// requestCodec returns the codec for the Content-Type of the request.
+// Requests without Content-Type use the first codec.
+func requestCodec(r *http.Request, codecs []mediaCodec) (Codec, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return codecs[0].codec, nil
+	}
+
+	mediaType, _, e := mime.ParseMediaType(contentType)
+	if e != nil {
+		return nil, BadRequest("invalid Content-Type '%s'", contentType)
+	}
+	mc, ok := findCodec(codecs, mediaType)
+	if !ok {
+		return nil, NewError(http.StatusUnsupportedMediaType, "unsupported Content-Type '%s'", mediaType)
+	}
+	return mc.codec, nil
+}
+
+// acceptRange is a single media range of an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiate returns the index of the codec to respond with for the Accept header, or -1 if none is acceptable.
+// The codec with the highest quality wins, ties are decided by the order of the codecs.
+// The quality of a codec is taken from the most specific media range matching it.
+func negotiate(accept string, codecs []mediaCodec) int {
+	if strings.TrimSpace(accept) == "" {
+		return 0
+	}
+	ranges := parseAccept(accept)
+
+	best, bestQ := -1, 0.0
+	for i, mc := range codecs {
+		q, specificity := 0.0, -1
+		for _, ar := range ranges {
+			if s := matchRange(ar.mediaType, mc.mediaType); s > specificity {
+				q, specificity = ar.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header, invalid ranges are skipped.
+func parseAccept(accept string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, e := mime.ParseMediaType(strings.TrimSpace(part))
+		if e != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, e = strconv.ParseFloat(qs, 64); e != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// matchRange returns how specific the media range matches the media type:
+// 2 for the same type, 1 for 'type/*', 0 for '*/*' and -1 if it does not match.
+func matchRange(mediaRange, mediaType string) int {
+	if mediaRange == mediaType {
+		return 2
+	}
+	if mediaRange == "*/*" {
+		return 0
+	}
+	if strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]) {
+		return 1
+	}
+	return -1
+}
+
+// JSONCodec encodes and decodes JSON.
+// Decoding reports every value that does not match the body type, see DecodeError.
+type JSONCodec struct {
+	Settings JSONSettings
+}
+
+func (c *JSONCodec) Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var body interface{}
+	if e := dec.Decode(&body); e != nil {
+		return e
+	}
+	if _, e := dec.Token(); e != io.EOF {
+		return fmt.Errorf("invalid data after top-level value")
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	value, e := parseTypeToValue(body, rv.Type())
+	if e != nil {
+		return e
+	}
+	rv.Set(value)
+	return nil
+}
+
+func (c *JSONCodec) Encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	if c.Settings.UseIndent {
+		enc.SetIndent(c.Settings.Prefix, c.Settings.Indent)
+	}
+	return enc.Encode(v)
+}
+
+// XMLCodec encodes and decodes XML with encoding/xml.
+// Slices are encoded as elements of an 'items' root element.
+type XMLCodec struct{}
+
+func (c *XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (c *XMLCodec) Encode(w io.Writer, v interface{}) error {
+	if _, e := io.WriteString(w, xml.Header); e != nil {
+		return e
+	}
+	enc := xml.NewEncoder(w)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return enc.Encode(v)
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: "items"}}
+	if e := enc.EncodeToken(root); e != nil {
+		return e
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if e := enc.Encode(rv.Index(i).Interface()); e != nil {
+			return e
+		}
+	}
+	if e := enc.EncodeToken(root.End()); e != nil {
+		return e
+	}
+	return enc.Flush()
+}
+
+// YAMLCodec encodes and decodes YAML with gopkg.in/yaml.v2.
+// Field names follow the JSON names of the types, like JSONCodec.
+type YAMLCodec struct{}
+
+func (c *YAMLCodec) Decode(r io.Reader, v interface{}) error {
+	data, e := ioutil.ReadAll(r)
+	if e != nil {
+		return e
+	}
+	var body interface{}
+	if e := yaml.Unmarshal(data, &body); e != nil {
+		return e
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	value, e := parseTypeToValue(jsonValue(body), rv.Type())
+	if e != nil {
+		return e
+	}
+	rv.Set(value)
+	return nil
+}
+
+func (c *YAMLCodec) Encode(w io.Writer, v interface{}) error {
+	// YAML is a superset of JSON, encoding as JSON first applies the JSON names
+	data, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+	var body interface{}
+	if e := yaml.Unmarshal(data, &body); e != nil {
+		return e
+	}
+	if data, e = yaml.Marshal(body); e != nil {
+		return e
+	}
+	_, e = w.Write(data)
+	return e
+}
+
+// jsonValue converts an unmarshalled YAML value to the types of unmarshalled JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		return v
+	}
+}
+
+// CSVCodec encodes and decodes CSV.
+//
+// Slices of structs are encoded with a header row of the JSON field names and a row per element,
+// a single struct as header and one row. Slices of scalars are encoded as a single column,
+// [][]string as rows. Decoding reads slices of structs, matching the header to the JSON field names,
+// and converts the values like query parameters.
+type CSVCodec struct{}
+
+func (c *CSVCodec) Encode(w io.Writer, v interface{}) error {
+	cw := csv.NewWriter(w)
+
+	if rows, ok := v.([][]string); ok {
+		if e := cw.WriteAll(rows); e != nil {
+			return e
+		}
+		return nil
+	}
+	if v == nil {
+		return nil
+	}
+
+	// A nil pointer is encoded as the header of its type without rows
+	rt := reflect.TypeOf(v)
+	rv := reflect.ValueOf(v)
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+		if rv.IsValid() && !rv.IsNil() {
+			rv = rv.Elem()
+		} else {
+			rv = reflect.Value{}
+		}
+	}
+
+	elemType := rt
+	single := rt.Kind() == reflect.Struct
+	if !single {
+		if rt.Kind() != reflect.Slice && rt.Kind() != reflect.Array {
+			return fmt.Errorf("csv: can not encode %s", rt)
+		}
+		elemType = rt.Elem()
+	}
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	length := 0
+	if rv.IsValid() && !single {
+		length = rv.Len()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		for i := 0; i < length; i++ {
+			if e := cw.Write([]string{csvString(rv.Index(i))}); e != nil {
+				return e
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	fields := jsonFields(elemType)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	if e := cw.Write(header); e != nil {
+		return e
+	}
+
+	writeRow := func(ev reflect.Value) error {
+		row := make([]string, len(fields))
+		ev = reflect.Indirect(ev)
+		if ev.IsValid() {
+			for i, f := range fields {
+				if fv, e := ev.FieldByIndexErr(f.index); e == nil {
+					row[i] = csvString(fv)
+				}
+			}
+		}
+		return cw.Write(row)
+	}
+	if single {
+		if rv.IsValid() {
+			if e := writeRow(rv); e != nil {
+				return e
+			}
+		}
+	} else {
+		for i := 0; i < length; i++ {
+			if e := writeRow(rv.Index(i)); e != nil {
+				return e
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvString formats a single value, nil pointers are empty and slices comma separated.
+func csvString(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(fmt.Stringer); ok {
+		return m.String()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = csvString(v.Index(i))
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func (c *CSVCodec) Decode(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("csv: can not decode into %s", rv.Type())
+	}
+	elemType := rv.Type().Elem()
+
+	records, e := csv.NewReader(r).ReadAll()
+	if e != nil {
+		return e
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]jsonField)
+	for _, f := range jsonFields(elemType) {
+		byName[f.name] = f
+	}
+	columns := make([]*jsonField, len(records[0]))
+	for i, name := range records[0] {
+		f, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("csv: unknown column '%s'", name)
+		}
+		if !isParamGoType(f.typ) {
+			return fmt.Errorf("csv: column '%s' has unsupported type %s", name, f.typ)
+		}
+		columns[i] = &f
+	}
+
+	d := &decodeState{}
+	slice := reflect.MakeSlice(rv.Type(), 0, len(records)-1)
+	for row, record := range records[1:] {
+		ev := reflect.New(elemType).Elem()
+		d.pushIndex(row)
+		for i, s := range record {
+			f := columns[i]
+			if s == "" {
+				continue
+			}
+			values := []string{s}
+			if f.typ.Kind() == reflect.Slice {
+				values = strings.Split(s, ",")
+			}
+			d.pushKey(f.name)
+			fv, e := parseValues(values, f.typ)
+			if e != nil {
+				d.errors = append(d.errors, FieldError{Field: d.pathString(), Reason: e.Error(), Expected: f.typ.String()})
+			} else {
+				ev.FieldByIndex(f.index).Set(fv)
+			}
+			d.pop()
+		}
+		d.pop()
+		slice = reflect.Append(slice, ev)
+	}
+	if d.errors != nil {
+		return &DecodeError{Errors: d.errors}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+// writeResult encodes the result with the codec and writes it with the status code.
+// The result is encoded completely before writing, to respond with an error if encoding fails.
+func (c *Controller) writeResult(w http.ResponseWriter, r *http.Request, mc mediaCodec, status int, result interface{}) {
+	var buf bytes.Buffer
+	if e := mc.codec.Encode(&buf, result); e != nil {
+		c.internalError(w, r, fmt.Errorf("could not encode response as %s: %v", mc.mediaType, e))
+		return
+	}
+	w.Header().Set("Content-Type", mc.mediaType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// mediaTypeList returns the media types of the codecs.
+func mediaTypeList(codecs []mediaCodec) string {
+	types := make([]string, len(codecs))
+	for i, mc := range codecs {
+		types[i] = mc.mediaType
+	}
+	return strings.Join(types, ", ")
+}
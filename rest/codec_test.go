@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type csvRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestCSVCodecEncodeNil(t *testing.T) {
+	var nilRow *csvRecord
+	var nilRows []csvRecord
+	var nilRowsPtr *[]csvRecord
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"nil interface", nil, ""},
+		{"nil struct pointer", nilRow, "name,count\n"},
+		{"nil slice", nilRows, "name,count\n"},
+		{"nil slice pointer", nilRowsPtr, "name,count\n"},
+		{"rows", []csvRecord{{"a", 1}}, "name,count\na,1\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if e := (&CSVCodec{}).Encode(&buf, test.v); e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if buf.String() != test.want {
+				t.Errorf("got %q, want %q", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+type csvRecordModule struct{}
+
+func (csvRecordModule) NilRow() (*csvRecord, error) { return nil, nil }
+
+func TestCSVResponseNilPointer(t *testing.T) {
+	c := NewController()
+	c.AddModule(csvRecordModule{})
+	c.Requests = []Request{{Name: "nil row", Func: "NilRow", Method: "GET", URI: "/row", Produces: []string{MediaTypeCSV}}}
+
+	r := httptest.NewRequest("GET", "/row", nil)
+	r.Header.Set("Accept", MediaTypeCSV)
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "name,count\n" {
+		t.Errorf("got body %q", w.Body.String())
+	}
+}
+
+type scalarModule struct{}
+
+func (scalarModule) Version() (string, error) { return "1.0", nil }
+
+func TestDefaultProducesJSONOnly(t *testing.T) {
+	c := NewController()
+	c.AddModule(scalarModule{})
+	c.Requests = []Request{{Name: "version", Func: "Version", Method: "GET", URI: "/version"}}
+
+	r := httptest.NewRequest("GET", "/version", nil)
+	r.Header.Set("Accept", MediaTypeCSV)
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, r)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Accept csv: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	doc, e := c.OpenAPI()
+	if e != nil {
+		t.Fatal(e)
+	}
+	var mediaTypes []string
+	for mt := range doc.Paths["/version"].Get.Responses["200"].Content {
+		mediaTypes = append(mediaTypes, mt)
+	}
+	sort.Strings(mediaTypes)
+	if got := strings.Join(mediaTypes, ","); got != MediaTypeJSON {
+		t.Errorf("response media types %s, want %s", got, MediaTypeJSON)
+	}
+}
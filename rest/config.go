@@ -0,0 +1,345 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFormat is the format of a route configuration.
+type ConfigFormat int
+
+const (
+	ConfigYAML ConfigFormat = iota
+	ConfigJSON
+)
+
+func (f ConfigFormat) unmarshal(data []byte, v interface{}) error {
+	if f == ConfigJSON {
+		return json.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// formatOf returns the format of a file by its extension, '.json' for JSON and YAML otherwise.
+func formatOf(name string) ConfigFormat {
+	if strings.EqualFold(path.Ext(name), ".json") {
+		return ConfigJSON
+	}
+	return ConfigYAML
+}
+
+// configDocument is the content of a configuration file.
+//
+// A file is either a list of routes or an object with routes, groups and includes, e.g.
+//
+//	include:
+//	  - "messages.yaml"
+//	  - "admin/*.yaml"
+//	routes:
+//	  - name: "health"
+//	    ...
+//	groups:
+//	  - prefix: "/v1/admin"
+//	    middleware: ["auth", "audit"]
+//	    routes:
+//	      ...
+//
+// Included files are resolved relative to the including file and may be glob patterns.
+// Their routes are added before the routes of the including file.
+type configDocument struct {
+	Include []string     `json:"include,omitempty" yaml:"include,omitempty"`
+	Routes  []Request    `json:"routes,omitempty" yaml:"routes,omitempty"`
+	Groups  []RouteGroup `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// requests returns the routes of the document followed by the routes of its groups.
+func (d configDocument) requests() ([]Request, error) {
+	return RouteGroup{Routes: d.Routes, Groups: d.Groups}.requests()
+}
+
+// UnmarshalYAML accepts a list of routes or an object.
+func (d *configDocument) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if e := unmarshal(&raw); e != nil {
+		return e
+	}
+	if _, ok := raw.([]interface{}); ok {
+		return unmarshal(&d.Routes)
+	}
+
+	type plain configDocument
+	return unmarshal((*plain)(d))
+}
+
+// UnmarshalJSON accepts a list of routes or an object.
+func (d *configDocument) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return json.Unmarshal(data, &d.Routes)
+	}
+
+	type plain configDocument
+	return json.Unmarshal(data, (*plain)(d))
+}
+
+// configFile is a configuration file added to the Controller.
+// Files of a fs.FS are read from fsys, files of the operating system if fsys is nil.
+type configFile struct {
+	fsys   fs.FS
+	path   string
+	format ConfigFormat
+}
+
+// load reads the requests of the file and its includes.
+// It also returns the paths of all files read.
+func (f configFile) load() ([]Request, []string, error) {
+	l := &configLoader{fsys: f.fsys, loading: make(map[string]bool)}
+	requests, e := l.load(f.path, f.format)
+	if e != nil {
+		return nil, nil, e
+	}
+	if e := checkParamTypes(requests); e != nil {
+		return nil, nil, e
+	}
+	return requests, l.files, nil
+}
+
+// checkParamTypes checks the types of the URL and query parameters when the configuration is loaded.
+// Routes with unsupported types are listed in a *ConfigError.
+func checkParamTypes(requests []Request) error {
+	report := &ConfigError{}
+	for _, rqst := range requests {
+		if e := paramTypeError(rqst); e != nil {
+			report.Routes = append(report.Routes, &RouteError{
+				Route:  rqst.Name,
+				Method: rqst.Method,
+				URI:    rqst.URI,
+				Err:    e,
+			})
+		}
+	}
+	if len(report.Routes) > 0 {
+		return report
+	}
+	return nil
+}
+
+// paramTypeError returns the error of the first parameter of the request with an unsupported type.
+func paramTypeError(rqst Request) error {
+	for _, p := range rqst.Params {
+		if _, e := p.Type.goType(); e != nil {
+			return fmt.Errorf("URL parameter '%s': %v", p.Name, e)
+		}
+	}
+	for _, q := range rqst.Query {
+		if _, e := q.Type.goType(); e != nil {
+			return fmt.Errorf("query parameter '%s': %v", q.Name, e)
+		}
+	}
+	return nil
+}
+
+// configLoader reads a configuration file and its includes.
+type configLoader struct {
+	fsys    fs.FS
+	loading map[string]bool
+	files   []string
+}
+
+func (l *configLoader) load(name string, format ConfigFormat) ([]Request, error) {
+	if l.loading[name] {
+		return nil, fmt.Errorf("%s: include cycle", name)
+	}
+	l.loading[name] = true
+	defer delete(l.loading, name)
+
+	data, e := l.readFile(name)
+	if e != nil {
+		return nil, e
+	}
+	l.files = append(l.files, name)
+
+	doc := configDocument{}
+	if e := format.unmarshal(data, &doc); e != nil {
+		return nil, fmt.Errorf("%s: %v", name, e)
+	}
+
+	requests := []Request{}
+	for _, include := range doc.Include {
+		matches, e := l.glob(l.join(name, include))
+		if e != nil {
+			return nil, fmt.Errorf("%s: include '%s': %v", name, include, e)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: include '%s' matches no files", name, include)
+		}
+		for _, m := range matches {
+			included, e := l.load(m, formatOf(m))
+			if e != nil {
+				return nil, e
+			}
+			requests = append(requests, included...)
+		}
+	}
+
+	docRequests, e := doc.requests()
+	if e != nil {
+		return nil, fmt.Errorf("%s: %v", name, e)
+	}
+	for _, rqst := range docRequests {
+		rqst.source = name
+		requests = append(requests, rqst)
+	}
+	return requests, nil
+}
+
+func (l *configLoader) readFile(name string) ([]byte, error) {
+	if l.fsys != nil {
+		return fs.ReadFile(l.fsys, name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func (l *configLoader) glob(pattern string) ([]string, error) {
+	if l.fsys != nil {
+		return fs.Glob(l.fsys, pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+// join resolves an included path relative to the including file.
+func (l *configLoader) join(including, include string) string {
+	if l.fsys != nil {
+		return path.Join(path.Dir(including), include)
+	}
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(including), include)
+}
+
+// AddRequestConfigFromReader reads route configuration in the format from r.
+// Includes are not supported, as there is no file to resolve them against.
+func (c *Controller) AddRequestConfigFromReader(r io.Reader, format ConfigFormat) error {
+	data, e := ioutil.ReadAll(r)
+	if e != nil {
+		return e
+	}
+
+	doc := configDocument{}
+	if e := format.unmarshal(data, &doc); e != nil {
+		return e
+	}
+	if len(doc.Include) > 0 {
+		return fmt.Errorf("includes are only supported in configuration files")
+	}
+
+	requests, e := doc.requests()
+	if e != nil {
+		return e
+	}
+	if e := checkParamTypes(requests); e != nil {
+		return e
+	}
+	c.mu.Lock()
+	c.Requests = append(c.Requests, requests...)
+	c.mu.Unlock()
+	return nil
+}
+
+// AddRequestConfigFromFS reads the route configuration files of fsys matching the glob patterns,
+// e.g. from an embed.FS. Files ending in '.json' are read as JSON, all others as YAML.
+func (c *Controller) AddRequestConfigFromFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		matches, e := fs.Glob(fsys, pattern)
+		if e != nil {
+			return e
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("pattern '%s' matches no files", pattern)
+		}
+		for _, m := range matches {
+			if e := c.addConfigFile(configFile{fsys: fsys, path: m, format: formatOf(m)}); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// AddRequestConfigFromGlob reads the route configuration files matching the glob patterns,
+// e.g. 'routes/*.yaml'. Files ending in '.json' are read as JSON, all others as YAML.
+func (c *Controller) AddRequestConfigFromGlob(patterns ...string) error {
+	for _, pattern := range patterns {
+		matches, e := filepath.Glob(pattern)
+		if e != nil {
+			return e
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("pattern '%s' matches no files", pattern)
+		}
+		for _, m := range matches {
+			if e := c.addConfigFile(configFile{path: m, format: formatOf(m)}); e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Controller) addConfigFile(f configFile) error {
+	requests, files, e := f.load()
+	if e != nil {
+		return e
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Requests = append(c.Requests, requests...)
+	c.configFiles = append(c.configFiles, f)
+	if f.fsys == nil {
+		c.watchedFiles = append(c.watchedFiles, files...)
+	}
+	return nil
+}
+
+// routeKey identifies the route of a request by method and URI, ignoring the names and patterns of URL parameters.
+func routeKey(rqst Request) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(rqst.Method) + " ")
+	depth := 0
+	for _, r := range rqst.URI {
+		switch {
+		case r == '{':
+			if depth == 0 {
+				b.WriteString("{}")
+			}
+			depth++
+		case r == '}':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// duplicateError returns the error for a request with the same route as an earlier request.
+func duplicateError(rqst, first Request) error {
+	where := ""
+	if first.source != "" {
+		where = " in " + first.source
+	}
+	if rqst.source != "" {
+		return fmt.Errorf("%s: duplicate of route '%s'%s", rqst.source, first.Name, where)
+	}
+	return fmt.Errorf("duplicate of route '%s'%s", first.Name, where)
+}
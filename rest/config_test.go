@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const unsupportedParamRoutes = `
+- {name: get, func: Get, method: GET, uri: '/items/{id}', params: {id: integer}}
+- {name: list, func: List, method: GET, uri: /items, query: [{name: limit, type: number}]}
+- {name: ok, func: Get, method: GET, uri: '/ok/{id}', params: {id: int}}
+`
+
+func TestLoadRejectsUnsupportedParamTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if e := os.WriteFile(path, []byte(unsupportedParamRoutes), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	loaders := map[string]func(c *Controller) error{
+		"reader": func(c *Controller) error {
+			return c.AddRequestConfigFromReader(strings.NewReader(unsupportedParamRoutes), ConfigYAML)
+		},
+		"file": func(c *Controller) error { return c.AddRequestConfigFromYAML(path) },
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			c := NewController()
+			e := load(c)
+
+			var ce *ConfigError
+			if !errors.As(e, &ce) {
+				t.Fatalf("error %v, want *ConfigError", e)
+			}
+			if len(ce.Routes) != 2 || ce.Routes[0].Route != "get" || ce.Routes[1].Route != "list" {
+				t.Errorf("broken routes: %v", e)
+			}
+			if len(c.Requests) != 0 {
+				t.Errorf("%d requests added", len(c.Requests))
+			}
+		})
+	}
+}
+
+func TestAddRequestConfigFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"routes/main.yaml": {Data: []byte(`
+include: ["items/*.yaml", "../shared/health.json"]
+routes:
+  - {name: main, func: Get, method: GET, uri: '/main/{id}', params: {id: int}}
+`)},
+		"routes/items/a.yaml": {Data: []byte(`[{name: a, func: Get, method: GET, uri: '/a/{id}', params: {id: int}}]`)},
+		"routes/items/b.yaml": {Data: []byte(`[{name: b, func: Get, method: GET, uri: '/b/{id}', params: {id: int}}]`)},
+		"shared/health.json":  {Data: []byte(`[{"name": "health", "func": "Get", "method": "GET", "uri": "/health/{id}", "params": {"id": "int"}}]`)},
+		"other.yaml":          {Data: []byte(`[{name: other, func: Get, method: GET, uri: '/other/{id}', params: {id: int}}]`)},
+	}
+
+	c := NewController()
+	c.AddModule(itemModule{})
+	if e := c.AddRequestConfigFromFS(fsys, "routes/*.yaml", "*.yaml"); e != nil {
+		t.Fatal(e)
+	}
+	names := make([]string, len(c.Requests))
+	for i, rqst := range c.Requests {
+		names[i] = rqst.Name
+	}
+	if got := strings.Join(names, ","); got != "a,b,health,main,other" {
+		t.Errorf("routes %s", got)
+	}
+	if e := c.Validate(); e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAddRequestConfigFromFSErrors(t *testing.T) {
+	tests := map[string]struct {
+		fsys fstest.MapFS
+		err  string
+	}{
+		"include cycle": {fstest.MapFS{
+			"a.yaml":     {Data: []byte(`{include: [sub/b.yaml]}`)},
+			"sub/b.yaml": {Data: []byte(`{include: [../a.yaml]}`)},
+		}, "a.yaml: include cycle"},
+		"include itself": {fstest.MapFS{
+			"a.yaml": {Data: []byte(`{include: [a.yaml]}`)},
+		}, "a.yaml: include cycle"},
+		"include without matches": {fstest.MapFS{
+			"a.yaml": {Data: []byte(`{include: [missing/*.yaml]}`)},
+		}, "a.yaml: include 'missing/*.yaml' matches no files"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewController()
+			e := c.AddRequestConfigFromFS(test.fsys, "a.yaml")
+			if e == nil || e.Error() != test.err {
+				t.Errorf("error %v, want %s", e, test.err)
+			}
+		})
+	}
+
+	c := NewController()
+	if e := c.AddRequestConfigFromFS(fstest.MapFS{}, "*.yaml"); e == nil {
+		t.Error("no error for a pattern without matches")
+	}
+}
+
+func TestDuplicateRoutesAcrossFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.yaml": {Data: []byte(`[{name: first, func: Get, method: GET, uri: '/x/{id}', params: {id: int}}]`)},
+		"b.yaml": {Data: []byte(`[{name: second, func: Get, method: get, uri: '/x/{key}', params: {key: int}}]`)},
+	}
+	c := NewController()
+	c.AddModule(itemModule{})
+	if e := c.AddRequestConfigFromFS(fsys, "*.yaml"); e != nil {
+		t.Fatal(e)
+	}
+
+	var ce *ConfigError
+	if e := c.Validate(); !errors.As(e, &ce) {
+		t.Fatalf("error %v, want *ConfigError", e)
+	}
+	if len(ce.Routes) != 1 || ce.Routes[0].Route != "second" {
+		t.Fatalf("broken routes: %v", ce)
+	}
+	if msg := ce.Routes[0].Err.Error(); msg != "b.yaml: duplicate of route 'first' in a.yaml" {
+		t.Errorf("error %q", msg)
+	}
+}
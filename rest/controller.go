@@ -1,13 +1,13 @@
 package rest
 
 import (
-	"encoding/json"
-	"log"
-	"os"
-
-	"gopkg.in/yaml.v2"
+	"log/slog"
+	"net/http"
+	"sync"
 
 	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Controller handles HTTP requests by parsing request information and passing
@@ -22,77 +22,153 @@ import (
 //
 type Controller struct {
 	*chi.Mux
-	Modules  []IModule
-	Requests []Request
+	Modules      []IModule
+	NamedModules map[string]IModule
+	Requests     []Request
+
+	logger *slog.Logger
+
+	rw           IResponseWriter
+	codecs       []mediaCodec
+	fileScanners []FileScanner
+	errorMapper  ErrorMapper
+	panicHook    PanicHook
+
+	openAPI    OpenAPISettings
+	middleware map[string]func(http.Handler) http.Handler
+	metrics    *metrics
+	accessLog  bool
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
 
-	logger *log.Logger
+	requestIDHeader string
 
-	rw IResponseWriter
+	configFiles  []configFile
+	watchedFiles []string
+	hotReload    *hotReload
+
+	// mu guards Requests and watchedFiles, which are replaced by hot reloads
+	mu sync.RWMutex
 }
 
 // NewController creates a new controller instance with default settings
 func NewController() *Controller {
-	return &Controller{
-		Mux:    chi.NewMux(),
-		logger: log.New(os.Stderr, "go-api", log.LstdFlags),
-		rw:     &StdJSONWriter{},
+	c := &Controller{
+		Mux:          chi.NewMux(),
+		NamedModules: make(map[string]IModule),
+		logger:       defaultLogger(),
 	}
+	c.AddCodec(MediaTypeJSON, &JSONCodec{})
+	c.addCodec(MediaTypeXML, &XMLCodec{}, true)
+	c.addCodec(MediaTypeYAML, &YAMLCodec{}, true)
+	c.addCodec(MediaTypeCSV, &CSVCodec{}, true)
+	return c
 }
 
 // IModule represents implementations of business logic.
 // The methods of IModule will be called by the Controlle.
 type IModule interface{}
 
-// SetModule sets a new module that should be called when a request is handled.
+// AddModule adds a module whose methods are called when a request is handled.
+//
+// Requests without a module name are routed to the only module implementing their func.
+// Adding the same module again has no effect.
 func (c *Controller) AddModule(m IModule) {
+	for _, added := range c.Modules {
+		if sameModule(added, m) {
+			return
+		}
+	}
 	c.Modules = append(c.Modules, m)
 }
 
-// SetLogger changes the logger used by the controller
-func (c *Controller) SetLogger(l *log.Logger) {
-	c.logger = l
+// AddNamedModule adds a module that requests can select with their 'module' setting.
+// The module is also searched for requests without a module name, once if it is added under several names.
+// A module added under a name already in use replaces the previous module,
+// which is no longer searched unless it is also added under another name.
+func (c *Controller) AddNamedModule(name string, m IModule) {
+	previous, replaced := c.NamedModules[name]
+	c.NamedModules[name] = m
+	if replaced && !sameModule(previous, m) {
+		c.removeModule(previous)
+	}
+	c.AddModule(m)
 }
 
-// SetLogger changes the logger used by the controller
+// removeModule removes the module from the modules searched for requests without a module name,
+// unless it is still added under a name.
+func (c *Controller) removeModule(m IModule) {
+	for _, named := range c.NamedModules {
+		if sameModule(named, m) {
+			return
+		}
+	}
+	for i, added := range c.Modules {
+		if sameModule(added, m) {
+			c.Modules = append(c.Modules[:i], c.Modules[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetWriter sets a writer for all results of module methods.
+// The writer replaces the content negotiation of responses, see AddCodec.
 func (c *Controller) SetWriter(r IResponseWriter) {
 	c.rw = r
 }
 
-// AddRoutes returns an HTTP route multiplexer, setup with the routes of the Controller.
+// Routes returns an HTTP route multiplexer, setup with the routes of the Controller.
+//
+// Routes panics if the route configuration does not match the module methods.
+// Call Validate beforehand to handle the error instead.
+//
+// With hot reload enabled, the routes are served by a router that is replaced when the configuration changes,
+// see EnableHotReload.
 func (c *Controller) Routes() *chi.Mux {
+	requests := c.currentRequests()
+	plans, e := c.buildPlans(requests)
+	if e != nil {
+		panic(e)
+	}
 
-	for _, rqst := range c.Requests {
-		c.MethodFunc(rqst.Method, rqst.URI, c.HandleRequest(rqst))
+	if c.hotReload != nil {
+		c.startHotReload(plans, requests)
+		return c.Mux
 	}
 
+	c.mountRoutes(c.Mux, plans, requests)
 	return c.Mux
 }
 
-// AddRequestConfigFromJSON reads and unmarshals JSON in the provided file path
-// to add route configuration
-func (c *Controller) AddRequestConfigFromJSON(filePath string) error {
-	data := readFileData(filePath)
+// mountRoutes registers the handlers of the plans and the OpenAPI document of the requests on the router.
+func (c *Controller) mountRoutes(router chi.Router, plans []*routePlan, requests []Request) {
+	for _, plan := range plans {
+		// Middleware runs inside the instrumentation, see instrument
+		handler := http.Handler(c.executePlan(plan))
+		if len(plan.middleware) > 0 {
+			handler = chi.Chain(plan.middleware...).Handler(handler)
+		}
+		router.Method(plan.request.Method, plan.request.URI, c.instrument(plan, handler))
+	}
+
+	if c.openAPI.Route != "" {
+		router.Get(c.openAPI.Route+".json", c.serveOpenAPI(requests, false))
+		router.Get(c.openAPI.Route+".yaml", c.serveOpenAPI(requests, true))
+	}
 
-	requests := []Request{}
-	if err := json.Unmarshal(data, &requests); err != nil {
-		return err
+	if c.metrics != nil && c.metrics.settings.Route != "" {
+		router.Method(http.MethodGet, c.metrics.settings.Route, c.MetricsHandler())
 	}
+}
 
-	c.Requests = append(c.Requests, requests...)
-	return nil
+// AddRequestConfigFromJSON reads and unmarshals JSON in the provided file path
+// to add route configuration
+func (c *Controller) AddRequestConfigFromJSON(filePath string) error {
+	return c.addConfigFile(configFile{path: filePath, format: ConfigJSON})
 }
 
 // AddRequestConfigFromYAML reads and unmarshals YAML in the provided file path
 // to add route configuration
 func (c *Controller) AddRequestConfigFromYAML(filePath string) error {
-	data := readFileData(filePath)
-
-	requests := []Request{}
-	err := yaml.Unmarshal(data, &requests)
-	if err != nil {
-		return err
-	}
-
-	c.Requests = append(c.Requests, requests...)
-	return nil
+	return c.addConfigFile(configFile{path: filePath, format: ConfigYAML})
 }
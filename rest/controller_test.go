@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type countModule struct{ n int }
+
+func (m *countModule) Count() (int, error) { return m.n, nil }
+
+func TestAddModuleTwice(t *testing.T) {
+	m := &countModule{n: 3}
+	c := NewController()
+	c.AddModule(m)
+	c.AddNamedModule("counter", m)
+	c.AddNamedModule("alias", m)
+	if len(c.Modules) != 1 {
+		t.Fatalf("%d modules, want 1", len(c.Modules))
+	}
+
+	c.Requests = []Request{{Name: "count", Func: "Count", Method: "GET", URI: "/count"}}
+	if e := c.Validate(); e != nil {
+		t.Fatal(e)
+	}
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/count", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "3\n" {
+		t.Errorf("status %d, body %q", w.Code, w.Body.String())
+	}
+
+	c.AddModule(&countModule{n: 4})
+	if e := c.Validate(); e == nil {
+		t.Error("distinct modules with the same method are not ambiguous")
+	}
+}
+
+func TestAddNamedModuleReplaces(t *testing.T) {
+	first, second, shared := &countModule{n: 1}, &countModule{n: 2}, &countModule{n: 3}
+	c := NewController()
+	c.AddNamedModule("counter", first)
+	c.AddNamedModule("counter", second)
+	if len(c.Modules) != 1 || c.Modules[0] != second || c.NamedModules["counter"] != second {
+		t.Fatalf("modules %v, want only the second module", c.Modules)
+	}
+
+	c.Requests = []Request{
+		{Name: "count", Func: "Count", Method: "GET", URI: "/count"},
+		{Name: "named", Func: "Count", Module: "counter", Method: "GET", URI: "/named"},
+	}
+	h := c.Routes()
+	for _, path := range []string{"/count", "/named"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != "2\n" {
+			t.Errorf("%s: body %q, want result of the second module", path, w.Body.String())
+		}
+	}
+
+	// A module still added under another name is kept
+	c = NewController()
+	c.AddNamedModule("a", shared)
+	c.AddNamedModule("b", shared)
+	c.AddNamedModule("a", first)
+	if len(c.Modules) != 2 || c.Modules[0] != shared || c.Modules[1] != first {
+		t.Errorf("modules %v, want shared and first module", c.Modules)
+	}
+}
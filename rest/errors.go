@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+// All error responses of the Controller are written as Problem.
+type Problem struct {
+	Type     string       `json:"type,omitempty"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+
+	// RequestID is the ID of the failed request, to find it in the logs
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// FieldError describes a problem with a single field or parameter of a request.
+// Decode errors also name the expected Go type and the received JSON type.
+type FieldError struct {
+	Field    string `json:"field"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Received string `json:"received,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// HTTPStatusCoder can be implemented by errors returned from module methods
+// to choose the HTTP status code of the error response.
+type HTTPStatusCoder interface {
+	HTTPStatus() int
+}
+
+// ErrorMapper converts an error to the problem written as response.
+// A nil Problem falls back to DefaultErrorMapper.
+type ErrorMapper func(e error) *Problem
+
+// Error is an error with an HTTP status code.
+// Use the constructors, e.g. NotFound or Validation, to create an Error.
+type Error struct {
+	Status int
+	Detail string
+	Fields []FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Detail, e.Err)
+	}
+	return e.Detail
+}
+
+// HTTPStatus returns the status code of the error.
+func (e *Error) HTTPStatus() int {
+	return e.Status
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError creates an Error with the status code and a formatted detail message.
+func NewError(status int, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Detail: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest creates an Error with status 400.
+func BadRequest(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, format, args...)
+}
+
+// Unauthorized creates an Error with status 401.
+func Unauthorized(format string, args ...interface{}) *Error {
+	return NewError(http.StatusUnauthorized, format, args...)
+}
+
+// Forbidden creates an Error with status 403.
+func Forbidden(format string, args ...interface{}) *Error {
+	return NewError(http.StatusForbidden, format, args...)
+}
+
+// NotFound creates an Error with status 404.
+func NotFound(format string, args ...interface{}) *Error {
+	return NewError(http.StatusNotFound, format, args...)
+}
+
+// Conflict creates an Error with status 409.
+func Conflict(format string, args ...interface{}) *Error {
+	return NewError(http.StatusConflict, format, args...)
+}
+
+// Validation creates an Error with status 422 listing the invalid fields.
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Detail: detail, Fields: fields}
+}
+
+// Internal creates an Error with status 500 wrapping the cause.
+// The cause is logged but not sent to the client.
+func Internal(cause error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Detail: "internal server error", Err: cause}
+}
+
+// DefaultErrorMapper converts errors to problems:
+//   - *Error is converted with its status, detail and fields.
+//   - Errors implementing HTTPStatusCoder get their status code and error message as detail.
+//   - All other errors result in status 500 without detail, to not leak internals.
+func DefaultErrorMapper(e error) *Problem {
+	var restErr *Error
+	if errors.As(e, &restErr) {
+		return &Problem{
+			Title:  http.StatusText(restErr.Status),
+			Status: restErr.Status,
+			Detail: restErr.Detail,
+			Errors: restErr.Fields,
+		}
+	}
+
+	var coder HTTPStatusCoder
+	if errors.As(e, &coder) {
+		status := coder.HTTPStatus()
+		return &Problem{
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: e.Error(),
+		}
+	}
+
+	return &Problem{
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	}
+}
+
+// SetErrorMapper changes how errors are converted to problem responses.
+func (c *Controller) SetErrorMapper(m ErrorMapper) {
+	c.errorMapper = m
+}
+
+// writeError converts the error to a problem and writes it as response.
+func (c *Controller) writeError(w http.ResponseWriter, r *http.Request, e error) {
+	var p *Problem
+	if c.errorMapper != nil {
+		p = c.errorMapper(e)
+	}
+	if p == nil {
+		p = DefaultErrorMapper(e)
+	}
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = string(RequestIDFromContext(r.Context()))
+	}
+
+	writeProblem(w, p)
+}
+
+// internalError responds with status 500 and logs the error.
+func (c *Controller) internalError(w http.ResponseWriter, r *http.Request, e error) {
+	c.writeError(w, r, Internal(e))
+	c.logRequest(r, "internal error", "error", e)
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
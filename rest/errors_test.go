@@ -0,0 +1,136 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errLocked = errors.New("account locked")
+
+// quotaError implements HTTPStatusCoder.
+type quotaError struct {
+	limit int
+}
+
+func (e quotaError) Error() string   { return fmt.Sprintf("quota of %d exceeded", e.limit) }
+func (e quotaError) HTTPStatus() int { return http.StatusTooManyRequests }
+
+type errorModule struct{}
+
+func (errorModule) Fail(kind string) (string, error) {
+	switch kind {
+	case "not-found":
+		return "", NotFound("order %d not found", 7)
+	case "wrapped-not-found":
+		return "", fmt.Errorf("loading order: %w", NotFound("order %d not found", 7))
+	case "quota":
+		return "", quotaError{limit: 10}
+	case "wrapped-quota":
+		return "", fmt.Errorf("sending: %w", quotaError{limit: 10})
+	case "locked":
+		return "", fmt.Errorf("login: %w", errLocked)
+	case "internal":
+		return "", Internal(errors.New("dial tcp 10.0.0.1:5432: secret-host"))
+	}
+	return "", errors.New("dial tcp 10.0.0.1:5432: secret-host")
+}
+
+func errorController(logs *bytes.Buffer) *Controller {
+	c := NewController()
+	c.SetLogHandler(slog.NewTextHandler(logs, nil))
+	c.AddModule(errorModule{})
+	c.Requests = []Request{{
+		Name: "fail", Func: "Fail", Method: "GET", URI: "/fail/{kind}",
+		Params: URLParams{{Name: "kind", Type: String}},
+	}}
+	return c
+}
+
+func fetchProblem(t *testing.T, h http.Handler, path string) (int, Problem, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("%s: content type %s", path, ct)
+	}
+	var problem Problem
+	if e := json.Unmarshal(w.Body.Bytes(), &problem); e != nil {
+		t.Fatalf("%s: %v", path, e)
+	}
+	return w.Code, problem, w.Body.String()
+}
+
+func TestDefaultErrorMapping(t *testing.T) {
+	var logs bytes.Buffer
+	h := errorController(&logs).Routes()
+
+	tests := []struct {
+		kind   string
+		status int
+		detail string
+	}{
+		{"not-found", http.StatusNotFound, "order 7 not found"},
+		{"wrapped-not-found", http.StatusNotFound, "order 7 not found"},
+		{"quota", http.StatusTooManyRequests, "quota of 10 exceeded"},
+		{"wrapped-quota", http.StatusTooManyRequests, "sending: quota of 10 exceeded"},
+		{"locked", http.StatusInternalServerError, ""},
+		{"internal", http.StatusInternalServerError, "internal server error"},
+		{"plain", http.StatusInternalServerError, ""},
+	}
+	for _, test := range tests {
+		status, problem, body := fetchProblem(t, h, "/fail/"+test.kind)
+		if status != test.status || problem.Status != test.status || problem.Detail != test.detail {
+			t.Errorf("%s: status %d, problem %+v, want %d, %q", test.kind, status, problem, test.status, test.detail)
+		}
+		if problem.Title != http.StatusText(test.status) || problem.Instance != "/fail/"+test.kind {
+			t.Errorf("%s: problem %+v", test.kind, problem)
+		}
+		if strings.Contains(body, "secret-host") {
+			t.Errorf("%s: internal error sent to the client: %s", test.kind, body)
+		}
+	}
+	if !strings.Contains(logs.String(), "secret-host") {
+		t.Errorf("internal error not logged: %s", logs.String())
+	}
+}
+
+func TestCustomErrorMapper(t *testing.T) {
+	var logs bytes.Buffer
+	c := errorController(&logs)
+	c.SetErrorMapper(func(e error) *Problem {
+		if errors.Is(e, errLocked) {
+			return &Problem{Type: "https://example.com/problems/locked", Status: http.StatusLocked, Detail: "account is locked"}
+		}
+		return nil
+	})
+	h := c.Routes()
+
+	status, problem, _ := fetchProblem(t, h, "/fail/locked")
+	want := Problem{
+		Type:     "https://example.com/problems/locked",
+		Title:    http.StatusText(http.StatusLocked),
+		Status:   http.StatusLocked,
+		Detail:   "account is locked",
+		Instance: "/fail/locked",
+	}
+	if problem.RequestID == "" {
+		t.Error("problem without request id")
+	}
+	want.RequestID = problem.RequestID
+	if status != http.StatusLocked || !reflect.DeepEqual(problem, want) {
+		t.Errorf("status %d, problem %+v, want %+v", status, problem, want)
+	}
+
+	// Errors the mapper does not handle fall back to the DefaultErrorMapper
+	if status, problem, _ := fetchProblem(t, h, "/fail/not-found"); status != http.StatusNotFound || problem.Detail != "order 7 not found" {
+		t.Errorf("status %d, problem %+v", status, problem)
+	}
+}
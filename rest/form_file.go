@@ -1,16 +1,37 @@
 package rest
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 )
 
 // FileInfo of a file found in a multiPart form request.
+//
+// Files bound to module methods are streamed to a temporary file at Path,
+// which is removed after the method returns.
 type FileInfo struct {
 	FileSize    int64
 	FileName    string
 	ContentType string
-	File        []byte
+	// SHA256 is the hex encoded SHA-256 digest of the content
+	SHA256 string
+	Path   string
+
+	// Deprecated: File is only set by ParseRequestFormFile, use Open to read the content.
+	File []byte
+}
+
+// Open opens the content of the file for reading.
+func (fi *FileInfo) Open() (io.ReadCloser, error) {
+	if fi.Path == "" {
+		return ioutil.NopCloser(bytes.NewReader(fi.File)), nil
+	}
+	return os.Open(fi.Path)
 }
 
 type MultipartFormValue string
@@ -46,7 +67,7 @@ func ParseRequestFormFile(r *http.Request, formName string) (*FileInfo, error) {
 
 	// Read file
 	buf := make([]byte, fi.FileSize)
-	_, e = file.Read(buf)
+	_, e = io.ReadFull(file, buf)
 	if e != nil {
 		return nil, e
 	}
@@ -58,7 +79,7 @@ func ParseRequestFormFile(r *http.Request, formName string) (*FileInfo, error) {
 
 	fileSplit := strings.Split(fileHeader.Filename, ".")
 	if len(fileSplit) < 2 {
-		return nil, e
+		return nil, fmt.Errorf("file name '%s' has no extension", fileHeader.Filename)
 	}
 
 	// Get file name
@@ -66,8 +87,8 @@ func ParseRequestFormFile(r *http.Request, formName string) (*FileInfo, error) {
 
 	// Check file type
 	buffer := make([]byte, 512)
-	n, e := file.Read(buffer)
-	if e != nil {
+	n, e := io.ReadFull(file, buffer)
+	if e != nil && e != io.ErrUnexpectedEOF && e != io.EOF {
 		return nil, e
 	}
 	// Reset file read to beginning
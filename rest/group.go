@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RouteGroup shares a URI prefix, headers and middleware between routes.
+//
+// The prefix is prepended to the URIs of the routes and nested groups. Headers and middleware of the group
+// come before those of the routes, middleware of outer groups before those of inner groups.
+// Middleware of a group only runs for requests matching one of its routes, like middleware of a chi Group.
+type RouteGroup struct {
+	Prefix     string       `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Middleware []string     `json:"middleware,omitempty" yaml:"middleware,omitempty"`
+	Headers    []string     `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Routes     []Request    `json:"routes,omitempty" yaml:"routes,omitempty"`
+	Groups     []RouteGroup `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// requests returns the routes of the group and its nested groups, with the settings of the groups applied.
+func (g RouteGroup) requests() ([]Request, error) {
+	if g.Prefix != "" && !strings.HasPrefix(g.Prefix, "/") {
+		return nil, fmt.Errorf("group prefix '%s' must begin with '/'", g.Prefix)
+	}
+	prefix := strings.TrimSuffix(g.Prefix, "/")
+
+	requests := []Request{}
+	for _, rqst := range g.Routes {
+		if prefix != "" && !strings.HasPrefix(rqst.URI, "/") {
+			return nil, fmt.Errorf("uri '%s' of route '%s' must begin with '/'", rqst.URI, rqst.Name)
+		}
+		rqst.URI = prefix + rqst.URI
+		rqst.Headers = joinNames(g.Headers, rqst.Headers)
+		rqst.Middleware = joinNames(g.Middleware, rqst.Middleware)
+		requests = append(requests, rqst)
+	}
+
+	for _, inner := range g.Groups {
+		if inner.Prefix != "" && !strings.HasPrefix(inner.Prefix, "/") {
+			return nil, fmt.Errorf("group prefix '%s' must begin with '/'", inner.Prefix)
+		}
+		inner.Prefix = prefix + inner.Prefix
+		inner.Headers = joinNames(g.Headers, inner.Headers)
+		inner.Middleware = joinNames(g.Middleware, inner.Middleware)
+		innerRequests, e := inner.requests()
+		if e != nil {
+			return nil, e
+		}
+		requests = append(requests, innerRequests...)
+	}
+	return requests, nil
+}
+
+// joinNames appends the names to the names of the group, skipping names the group already has.
+// It returns nil if neither has names, so routes without headers keep their signature.
+func joinNames(group, names []string) []string {
+	if len(group) == 0 {
+		return names
+	}
+	joined := append([]string{}, group...)
+	for _, name := range names {
+		found := false
+		for _, g := range group {
+			if g == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			joined = append(joined, name)
+		}
+	}
+	return joined
+}
+
+// AddMiddleware registers a middleware that routes and groups refer to by name in their 'middleware' setting.
+// Must be called before the routes are set up. The middleware runs after the request ID is set
+// and the request is traced, logged and counted in the metrics.
+func (c *Controller) AddMiddleware(name string, m func(http.Handler) http.Handler) {
+	if c.middleware == nil {
+		c.middleware = make(map[string]func(http.Handler) http.Handler)
+	}
+	c.middleware[name] = m
+}
+
+// routeMiddleware resolves the middleware names of the request.
+func (c *Controller) routeMiddleware(names []string) ([]func(http.Handler) http.Handler, error) {
+	middleware := make([]func(http.Handler) http.Handler, 0, len(names))
+	for _, name := range names {
+		m, ok := c.middleware[name]
+		if !ok {
+			return nil, fmt.Errorf("middleware '%s' not found", name)
+		}
+		middleware = append(middleware, m)
+	}
+	return middleware, nil
+}
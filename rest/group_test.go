@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteGroupPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		group RouteGroup
+		uris  []string
+	}{
+		{"no prefix", RouteGroup{Routes: []Request{{URI: "/a"}}}, []string{"/a"}},
+		{"prefix", RouteGroup{Prefix: "/v1", Routes: []Request{{URI: "/a"}}}, []string{"/v1/a"}},
+		{"trailing slash", RouteGroup{Prefix: "/v1/", Routes: []Request{{URI: "/a"}}}, []string{"/v1/a"}},
+		{"root prefix", RouteGroup{Prefix: "/", Routes: []Request{{URI: "/a"}}}, []string{"/a"}},
+		{"nested", RouteGroup{
+			Prefix: "/api/",
+			Routes: []Request{{URI: "/a"}},
+			Groups: []RouteGroup{{Prefix: "/v1/", Routes: []Request{{URI: "/b"}}, Groups: []RouteGroup{{Routes: []Request{{URI: "/c"}}}}}},
+		}, []string{"/api/a", "/api/v1/b", "/api/v1/c"}},
+	}
+	for _, test := range tests {
+		requests, e := test.group.requests()
+		if e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		uris := make([]string, len(requests))
+		for i, rqst := range requests {
+			uris[i] = rqst.URI
+		}
+		if !reflect.DeepEqual(uris, test.uris) {
+			t.Errorf("%s: uris %v, want %v", test.name, uris, test.uris)
+		}
+	}
+
+	invalid := map[string]RouteGroup{
+		"prefix without slash":        {Prefix: "v1", Routes: []Request{{URI: "/a"}}},
+		"nested prefix without slash": {Prefix: "/api", Groups: []RouteGroup{{Prefix: "v1", Routes: []Request{{URI: "/a"}}}}},
+		"uri without slash":           {Prefix: "/api", Routes: []Request{{Name: "a", URI: "a"}}},
+	}
+	for name, g := range invalid {
+		if _, e := g.requests(); e == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+type groupModule struct{}
+
+func (groupModule) Get(headers map[string]string, tenant string, id int) (map[string]string, error) {
+	headers["tenant"] = tenant
+	headers["id"] = strings.Repeat("x", id)
+	return headers, nil
+}
+
+const nestedGroups = `
+groups:
+  - prefix: /tenants/{tenant}/
+    middleware: [outer]
+    headers: [X-Tenant]
+    groups:
+      - prefix: /items
+        middleware: [inner]
+        headers: [X-User]
+        routes:
+          - name: get
+            func: Get
+            method: GET
+            uri: '/{id}'
+            middleware: [route, outer]
+            headers: [X-Trace]
+            params: {tenant: string, id: int}
+`
+
+func TestRouteGroupInheritance(t *testing.T) {
+	c := NewController()
+	c.AddModule(groupModule{})
+	for _, name := range []string{"outer", "inner", "route"} {
+		name := name
+		c.AddMiddleware(name, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+	if e := c.AddRequestConfigFromReader(strings.NewReader(nestedGroups), ConfigYAML); e != nil {
+		t.Fatal(e)
+	}
+	rqst := c.Requests[0]
+	if rqst.URI != "/tenants/{tenant}/items/{id}" {
+		t.Errorf("uri %s", rqst.URI)
+	}
+	if want := []string{"X-Tenant", "X-User", "X-Trace"}; !reflect.DeepEqual(rqst.Headers, want) {
+		t.Errorf("headers %v, want %v", rqst.Headers, want)
+	}
+
+	r := httptest.NewRequest("GET", "/tenants/acme/items/2", nil)
+	r.Header.Set("X-Tenant", "t")
+	r.Header.Set("X-User", "u")
+	r.Header.Set("X-Trace", "tr")
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	want := `{"X-Tenant":"t","X-Trace":"tr","X-User":"u","id":"xx","tenant":"acme"}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("body %s, want %s", w.Body.String(), want)
+	}
+	if order := w.Header()["X-Middleware"]; !reflect.DeepEqual(order, []string{"outer", "inner", "route"}) {
+		t.Errorf("middleware order %v", order)
+	}
+}
@@ -1,108 +1,139 @@
 package rest
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 )
 
-// HandleRequest is the function called by all routes that are defined.
+// HandleRequest returns the handler for a single request configuration.
 //
-// It will first check to see if the route method is found in the module.
-// It then proceeds to parse headers, any URL parameters, query parameters and body
-// The parsed values will then be passed as arguments to the method in the following order:
+// The module method is resolved and the argument binders are built once, see buildPlan.
+// If the request configuration is invalid, the handler responds with an internal error.
+//
+// The parsed values will be passed as arguments to the method in the following order:
 //		1. Headers
-// 		2. URL parameters, each as single argument, type according to configurations.
-// 		3. Query parameters as a map[string]string
+// 		2. URL parameters, each as single argument in the configured order, type according to configurations.
+// 		3. Query parameters as a map[string]string, as a struct (if configured with queryStruct)
+// 		   or each as single argument (if any query parameter declares a type).
 //		4. Body as struct (if configured as json) or as
 //			parameters in the order they are defined in the configuration (typed as either FileInfo or string).
 //
+// Parameters of type context.Context, *http.Request, http.ResponseWriter and RequestID are not part of this
+// order. They can be declared at any position and are injected with the values of the HTTP request.
+//
+// Values violating validation rules are rejected before the method is called,
+// with status 422 and the violations of all arguments.
+//
 // Two responses from the method call are expected: structure for response and an error.
-// If the error is nil, the response will be sent as JSON.
+// Methods may also return (T, int, error) to choose the status code.
+// Methods with an http.ResponseWriter parameter may write the response themselves and return only an error or nothing,
+// an error is then written like other errors and must be returned before anything is written.
+// If the error is nil, the response is encoded with the codec negotiated from the Accept header, see AddCodec.
+// Return a Response to set the status code, headers and cookies.
+// Otherwise the error is converted by the error mapper and sent as problem+json, see SetErrorMapper.
+// Panics of the method are recovered and sent as internal error, see OnPanic.
+// Binding, the method call and writing the response are traced if enabled, see SetTracing.
 func (c *Controller) HandleRequest(request Request) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var mod, fnValue reflect.Value
-
-		for _, module := range c.Modules {
-			// Get modules method by name
-			mod = reflect.ValueOf(module)
-			if !mod.IsValid() {
-				c.internalError(w, fmt.Errorf("controller module not set"))
-				return
-			}
-			fnValue = mod.MethodByName(request.Func)
-			if !fnValue.IsValid() {
-				c.internalError(w, fmt.Errorf("method '%s' not found", request.Func))
-				return
-			}
+	plan, e := c.buildPlan(request)
+	if e != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			r = c.withRequestID(w, r)
+			c.internalError(w, r, e)
 		}
+	}
+	return c.handlePlan(plan)
+}
 
-		// Make an empty argument list.
-		arguments := []reflect.Value{}
+// handlePlan returns the instrumented handler executing the plan for every request.
+func (c *Controller) handlePlan(plan *routePlan) http.HandlerFunc {
+	return c.instrument(plan, c.executePlan(plan))
+}
 
-		// Parse headers, if wanted by the request
-		if request.Headers != nil {
-			headers := make(map[string]string)
-			for _, name := range request.Headers {
-				headers[name] = r.Header.Get(name)
-			}
-			arguments = append(arguments, reflect.ValueOf(headers))
-		}
+// instrument wraps the handler of a route with the request ID, the server span, the request log and the metrics.
+// Route middleware is wrapped as well, so that requests rejected by middleware are recorded.
+func (c *Controller) instrument(plan *routePlan, next http.Handler) http.HandlerFunc {
+	rm := c.planMetrics(plan)
 
-		// Parse URL parameters if any available
-		if request.Params != nil {
-			params, e := parseURLParams(r, request.Params)
-			if e != nil {
-				c.internalError(w, e)
-				return
-			}
-			arguments = append(arguments, params...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = c.withRequestID(w, r)
+		r, span := c.traceRequest(r, plan)
+		w, r = c.startRequestLog(w, r, plan)
+		if rm != nil {
+			rm.begin()
 		}
+		defer c.finishRequest(r, rm)
+		defer endTrace(r, span)
 
-		// Parse query parameters if any available
-		if request.Query != nil {
-			var queryParams map[string]string
-			for _, key := range request.Query {
-				queryParams[key] = r.URL.Query().Get(key)
-			}
-			arguments = append(arguments, reflect.ValueOf(queryParams))
-		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// executePlan returns the handler binding the arguments, calling the module method and writing the response.
+func (c *Controller) executePlan(plan *routePlan) http.HandlerFunc {
+	request := plan.request
+	rm := c.planMetrics(plan)
 
-		// Parse JSON Body
-		if request.Body.IsJSON {
-			v, e := parseBodyJSON(r, request.Body.JSONStructName)
-			if e != nil {
-				c.internalError(w, e)
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Negotiate the response before calling the module
+		var produce mediaCodec
+		if c.rw == nil {
+			if len(plan.produces) > 1 {
+				w.Header().Add("Vary", "Accept")
+			}
+			i := negotiate(r.Header.Get("Accept"), plan.produces)
+			if i < 0 {
+				c.writeError(w, r, NewError(http.StatusNotAcceptable,
+					"none of the acceptable media types is available: %s", mediaTypeList(plan.produces)))
 				return
 			}
-			arguments = append(arguments, v)
+			produce = plan.produces[i]
 		}
 
-		// Parse Multipart Form Body
-		if request.Body.IsMultipart {
-			for _, form := range request.Body.Forms {
-				v, e := parseBodyMultipart(r, form)
-				if e != nil {
-					c.internalError(w, e)
-					return
-				}
-				arguments = append(arguments, v)
+		state := &callState{w: w, r: r}
+		defer state.cleanup()
+
+		_, bindSpan := c.startSpan(r.Context(), "bind arguments")
+		arguments, e := plan.arguments(state)
+		bindSpan.End()
+		if e != nil {
+			var coder HTTPStatusCoder
+			if !errors.As(e, &coder) {
+				c.internalError(w, r, e)
+				return
 			}
+			c.writeError(w, r, e)
+			return
 		}
 
 		// Call module function
-		fnResults := executeModuleCall(fnValue, arguments)
-
-		// Check response from module.
-		// Expect to have to values, response and error
-		if len(fnResults) != 2 {
-			c.internalError(w, fmt.Errorf("module function reponse does not contain two arguments"))
+		callCtx, callSpan := c.startSpan(r.Context(), "call module")
+		if callSpan.IsRecording() {
+			withCallContext(callCtx, plan, arguments)
+		}
+		fnResults, panicErr := executeModuleCall(plan.fn, arguments)
+		callSpan.End()
+		if panicErr != nil {
+			panicErr.Route = request.Name
+			c.recordError(r, panicErr)
+			c.recovered(w, r, panicErr)
 			return
 		}
 
 		// Get results
-		result := fnResults[0].Interface()
-		resultError := fnResults[1].Interface()
+		var result, resultError interface{}
+		if len(fnResults) > 0 {
+			resultError = fnResults[len(fnResults)-1].Interface()
+		}
+		if len(fnResults) > 1 {
+			result = fnResults[0].Interface()
+		}
+		status := 0
+		if len(fnResults) == 3 {
+			status = int(fnResults[1].Int())
+		}
 
 		// Respond to the HTTP request
 		if resultError != nil {
@@ -112,23 +143,40 @@ func (c *Controller) HandleRequest(request Request) http.HandlerFunc {
 				e = fmt.Errorf("%s", resultError)
 			}
 
-			c.logger.Printf("module error response to '%s': %v\n", request.Name, e)
+			if rm != nil {
+				rm.moduleError()
+			}
+			c.recordError(r, e)
+			_, writeSpan := c.startSpan(r.Context(), "write response")
+			c.writeError(w, r, e)
+			writeSpan.End()
+			c.logRequest(r, "module error response", "error", e)
+			return
+		}
 
-			c.rw.WriteError(w, e)
+		// The method wrote the response with the injected http.ResponseWriter
+		if len(fnResults) < 2 {
 			return
 		}
 
-		c.rw.Write(w, result)
+		_, writeSpan := c.startSpan(r.Context(), "write response")
+		c.writeResponse(w, r, plan, produce, status, result)
+		writeSpan.End()
 	}
 }
 
 // executeModuleCall wraps the module method call in a function to be able to recover from a panic.
 // This is due to the implementation of the go reflect package.
-func executeModuleCall(v reflect.Value, args []reflect.Value) []reflect.Value {
+//
+// http.ErrAbortHandler is panicked again, as it aborts the response on purpose.
+func executeModuleCall(v reflect.Value, args []reflect.Value) (results []reflect.Value, panicErr *PanicError) {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Println("Recovered in f", r)
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			panicErr = &PanicError{Value: r, Stack: debug.Stack()}
 		}
 	}()
-	return v.Call(args)
+	return v.Call(args), nil
 }
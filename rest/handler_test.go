@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type benchMessage struct {
+	Sender string `json:"sender"`
+	Text   string `json:"text"`
+	Count  int    `json:"count"`
+}
+
+type benchModule struct{}
+
+func (benchModule) Create(m benchMessage) (benchMessage, error) { return m, nil }
+func (benchModule) Get(id int, slug string) (int, error)        { return id, nil }
+
+func benchRouter(b *testing.B, rqst Request) http.Handler {
+	c := NewController()
+	c.AddModule(benchModule{})
+	c.Requests = []Request{rqst}
+	if e := c.Validate(); e != nil {
+		b.Fatal(e)
+	}
+	return c.Routes()
+}
+
+func BenchmarkHandleJSONBody(b *testing.B) {
+	AddTypeToRegistry(benchMessage{})
+	h := benchRouter(b, Request{
+		Name:   "create",
+		Func:   "Create",
+		Method: "POST",
+		URI:    "/messages",
+		Body:   BodyType{IsJSON: true, JSONStructName: "rest.benchMessage"},
+	})
+	body := `{"sender":"bench","text":"hello world","count":3}`
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest("POST", "/messages", strings.NewReader(body))
+		r.Header.Set("Content-Type", MediaTypeJSON)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d, body %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func BenchmarkHandleURLParam(b *testing.B) {
+	h := benchRouter(b, Request{
+		Name:   "get",
+		Func:   "Get",
+		Method: "GET",
+		URI:    "/messages/{id}/{slug}",
+		Params: URLParams{{Name: "id", Type: "int"}, {Name: "slug", Type: "string"}},
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest("GET", "/messages/42/hello", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d, body %s", w.Code, w.Body.String())
+		}
+	}
+}
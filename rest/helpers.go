@@ -1,121 +1,152 @@
 package rest
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"reflect"
-	"strconv"
-
-	"github.com/go-chi/chi"
+	"strings"
 )
 
-func (c *Controller) internalError(w http.ResponseWriter, e error) {
-	c.logger.Println(e.Error())
-	fmt.Fprintf(w, "INTERNAL ERROR: %v", e)
-	// http.Error(w, "internal server error", http.StatusInternalServerError)
-}
+// resolveMethod returns the module method called by the request.
+//
+// If the request names a module, the method is looked up in that module only.
+// Otherwise all modules are searched and the method must be implemented by exactly one of them.
+// Routes declared with a RouteBuilder call their handler.
+func (c *Controller) resolveMethod(rqst Request) (reflect.Value, error) {
+	if rqst.handler.IsValid() {
+		if rqst.handler.Kind() != reflect.Func || rqst.handler.IsNil() {
+			return reflect.Value{}, fmt.Errorf("handler must be a function, not %s", rqst.handler.Type())
+		}
+		return rqst.handler, nil
+	}
 
-func parseURLParams(r *http.Request, params map[string]string) ([]reflect.Value, error) {
-	arguments := []reflect.Value{}
-	for i, v := range params {
-		p := chi.URLParam(r, i)
-		if v == "string" {
-			arguments = append(arguments, reflect.ValueOf(p))
+	if rqst.Module != "" {
+		module, ok := c.NamedModules[rqst.Module]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("module '%s' not registered", rqst.Module)
 		}
-		if v == "int" {
-			pInt, e := strconv.Atoi(p)
-			if e != nil {
-				return nil, fmt.Errorf("could not parse URL paramter '%s': %v", i, e)
-			}
-			arguments = append(arguments, reflect.ValueOf(pInt))
+		fnValue := reflect.ValueOf(module).MethodByName(rqst.Func)
+		if !fnValue.IsValid() {
+			return reflect.Value{}, fmt.Errorf("method '%s' not found in module '%s'", rqst.Func, rqst.Module)
 		}
+		return fnValue, nil
 	}
 
-	return arguments, nil
-}
+	var fnValue reflect.Value
+	var found []string
+	for _, module := range c.Modules {
+		mod := reflect.ValueOf(module)
+		if !mod.IsValid() {
+			continue
+		}
+		if fn := mod.MethodByName(rqst.Func); fn.IsValid() {
+			fnValue = fn
+			found = append(found, c.moduleName(module))
+		}
+	}
 
-func parseBodyJSON(r *http.Request, bodyTypeName string) (bodyValue reflect.Value, e error) {
-	if TypeRegistry[bodyTypeName] == nil {
-		e = fmt.Errorf("json body type '%s' not found in type registry", bodyTypeName)
-		return
+	switch len(found) {
+	case 0:
+		return reflect.Value{}, fmt.Errorf("method '%s' not found", rqst.Func)
+	case 1:
+		return fnValue, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("method '%s' is ambiguous, found in modules %s; set 'module' on the route",
+			rqst.Func, strings.Join(found, ", "))
 	}
+}
 
-	// Create value and type of body struct
-	bodyValue = reflect.New(TypeRegistry[bodyTypeName]).Elem()
+// sameModule reports whether a and b are the same module, e.g. the same pointer.
+func sameModule(a, b IModule) bool {
+	t := reflect.TypeOf(a)
+	return t != nil && t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
 
-	// Unmarshal any json body to a map
-	var body interface{}
-	e = unmarshalBodyToJSON(r, &body)
-	if e != nil {
-		return bodyValue, fmt.Errorf("could not parse json body: %v", e)
+// moduleName returns the name the module was registered with, or its type.
+func (c *Controller) moduleName(module IModule) string {
+	if !reflect.TypeOf(module).Comparable() {
+		return fmt.Sprintf("%T", module)
 	}
-
-	// bodyType := bodyValue.Type()
-	valuePointer, e := parseTypeToValue(body, TypeRegistry[bodyTypeName])
-	if e != nil {
-		return bodyValue, fmt.Errorf("could not parse json body: %v", e)
+	for name, m := range c.NamedModules {
+		if m == module {
+			return "'" + name + "'"
+		}
 	}
-
-	return *valuePointer, nil
+	return fmt.Sprintf("%T", module)
 }
 
-func parseBodyMultipart(r *http.Request, form MultipartForm) (reflect.Value, error) {
-	if !form.IsFile {
-		// Parse request form
-		e := r.ParseMultipartForm(32 << 20)
-		if e != nil {
-			return reflect.Value{}, fmt.Errorf("could not parse multipart form: %v", e)
+// routeModule returns the name of the module implementing the method of the request, for logging.
+func (c *Controller) routeModule(rqst Request) string {
+	if rqst.Module != "" {
+		return rqst.Module
+	}
+	if rqst.handler.IsValid() {
+		module, _ := funcNames(rqst.handler)
+		return module
+	}
+	for _, module := range c.Modules {
+		if mod := reflect.ValueOf(module); mod.IsValid() && mod.MethodByName(rqst.Func).IsValid() {
+			return strings.Trim(c.moduleName(module), "'")
 		}
-
-		// Get file from request
-		v := r.FormValue(form.Name)
-		return reflect.ValueOf(v), nil
 	}
+	return ""
+}
 
-	fi, e := ParseRequestFormFile(r, form.Name)
-	if e != nil {
-		return reflect.Value{}, fmt.Errorf("could not parse request form file: %v", e)
+func headerBinder(names []string) argBinder {
+	return argBinder{
+		typ: stringMapType,
+		bind: func(s *callState) (reflect.Value, error) {
+			headers := make(map[string]string, len(names))
+			for _, name := range names {
+				headers[name] = s.r.Header.Get(name)
+			}
+			return reflect.ValueOf(headers), nil
+		},
 	}
-
-	return reflect.ValueOf(fi), nil
 }
 
-func unmarshalBodyToJSON(r *http.Request, model interface{}) error {
-	buf, err := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
-	if err != nil {
-		return err
+// bodyBinder decodes the body into the registered type with the codec of its Content-Type.
+func bodyBinder(body BodyType, consumes []mediaCodec) (argBinder, error) {
+	t := TypeRegistry[body.JSONStructName]
+	if t == nil {
+		return argBinder{}, fmt.Errorf("json body type '%s' not found in type registry", body.JSONStructName)
 	}
-	temp := ioutil.NopCloser(bytes.NewBuffer(buf))
-	defer temp.Close()
-
-	err = json.Unmarshal(buf, &model)
-	if err != nil {
-		return err
+	if len(consumes) == 0 {
+		return argBinder{}, fmt.Errorf("no codec for the body")
+	}
+	val, e := bodyValidator(t, body.Validate)
+	if e != nil {
+		return argBinder{}, fmt.Errorf("json body type '%s': %v", body.JSONStructName, e)
 	}
-	return nil
-}
 
-func readFileData(filePath string) []byte {
-	var err error
+	return argBinder{
+		typ: t,
+		bind: func(s *callState) (reflect.Value, error) {
+			codec, e := requestCodec(s.r, consumes)
+			if e != nil {
+				return reflect.Value{}, e
+			}
 
-	input := io.ReadCloser(os.Stdin)
-	if input, err = os.Open(filePath); err != nil {
-		log.Println(err)
-		log.Fatal(err)
-	}
+			defer s.r.Body.Close()
+			v := reflect.New(t)
+			if e := codec.Decode(s.r.Body, v.Interface()); e != nil {
+				var decodeErr *DecodeError
+				if errors.As(e, &decodeErr) {
+					return reflect.Value{}, &Error{
+						Status: http.StatusBadRequest,
+						Detail: "could not parse body",
+						Fields: decodeErr.Errors,
+						Err:    e,
+					}
+				}
+				return reflect.Value{}, BadRequest("could not parse body: %v", e)
+			}
 
-	data, err := ioutil.ReadAll(input)
-	input.Close()
-	if err != nil {
-		log.Println(err)
-		log.Fatal(err)
-	}
-	return data
+			if e := validationError(validateValue(val, v.Elem())); e != nil {
+				return reflect.Value{}, e
+			}
+			return v.Elem(), nil
+		},
+	}, nil
 }
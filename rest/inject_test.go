@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type claimsKey struct{}
+
+type injectModule struct{}
+
+func (injectModule) Info(ctx context.Context, id int, r *http.Request) (string, error) {
+	if e := ctx.Err(); e != nil {
+		return "", e
+	}
+	return fmt.Sprintf("%v %s %d", ctx.Value(claimsKey{}), r.Method, id), nil
+}
+
+func (injectModule) Stream(id int, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "stream %d", id)
+}
+
+func (injectModule) Check(w http.ResponseWriter, id int) error {
+	if id == 0 {
+		return NotFound("item %d not found", id)
+	}
+	fmt.Fprintf(w, "item %d", id)
+	return nil
+}
+
+func (injectModule) Nothing(id int) {}
+
+func TestInjectors(t *testing.T) {
+	c := NewController()
+	c.AddModule(injectModule{})
+	c.AddMiddleware("claims", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, "admin")))
+		})
+	})
+	params := URLParams{{Name: "id", Type: Int}}
+	c.Requests = []Request{
+		{Name: "info", Func: "Info", Method: "GET", URI: "/info/{id}", Params: params, Middleware: []string{"claims"}},
+		{Name: "stream", Func: "Stream", Method: "GET", URI: "/stream/{id}", Params: params},
+		{Name: "check", Func: "Check", Method: "GET", URI: "/check/{id}", Params: params},
+	}
+	h := c.Routes()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/info/1", http.StatusOK, "\"admin GET 1\"\n"},
+		{"/stream/2", http.StatusAccepted, "stream 2"},
+		{"/check/3", http.StatusOK, "item 3"},
+		{"/check/0", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: status %d, body %q", test.path, w.Code, w.Body.String())
+		}
+	}
+
+	// The context of the request is passed, so modules see its cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/info/1", nil).WithContext(ctx))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("canceled request: status %d", w.Code)
+	}
+}
+
+func TestInjectorsWithoutResult(t *testing.T) {
+	c := NewController()
+	c.AddModule(injectModule{})
+	c.Requests = []Request{{Name: "nothing", Func: "Nothing", Method: "GET", URI: "/x/{id}", Params: URLParams{{Name: "id", Type: Int}}}}
+
+	var ce *ConfigError
+	e := c.Validate()
+	if !errors.As(e, &ce) || !strings.Contains(e.Error(), "must return") {
+		t.Errorf("error %v, want error of the results", e)
+	}
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultLogger writes text lines to stderr.
+func defaultLogger() *slog.Logger {
+	return textLogger(os.Stderr)
+}
+
+// textLogger writes text lines with key=value fields and the component of the Controller to w.
+func textLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, nil)).With("component", "go-api")
+}
+
+// SetLogger changes the logger used by the controller.
+// Log records are written to the writer of l as text lines with key=value fields, see SetSlogLogger.
+// The prefix and flags of l are not used, the lines carry their own time and the field component=go-api.
+func (c *Controller) SetLogger(l *log.Logger) {
+	c.logger = textLogger(l.Writer())
+}
+
+// SetSlogLogger changes the logger used by the controller.
+//
+// Lines logged while handling a request carry the fields route, method, pattern, request_id, module and func,
+// and status and duration once the response is written. See SetRequestIDHeader for the request ID.
+func (c *Controller) SetSlogLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+// SetLogHandler changes the handler of the logger used by the controller, e.g. a slog.JSONHandler.
+func (c *Controller) SetLogHandler(h slog.Handler) {
+	c.logger = slog.New(h)
+}
+
+type requestLogKey struct{}
+
+// requestLog logs the lines of a single request with the fields of its route.
+type requestLog struct {
+	logger *slog.Logger
+	w      *recordingWriter
+	body   *countingReader
+	start  time.Time
+}
+
+// Logger returns the logger of the request handled with ctx, with the fields of its route.
+// Module methods get it from their context.Context parameter. Returns slog.Default() outside of requests.
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		return l.logger
+	}
+	return slog.Default()
+}
+
+// startRequestLog wraps the response writer and body to record the status and sizes
+// and adds the request logger to the context.
+func (c *Controller) startRequestLog(w http.ResponseWriter, r *http.Request, plan *routePlan) (http.ResponseWriter, *http.Request) {
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	l := &requestLog{
+		logger: c.logger.With(
+			"route", plan.request.Name,
+			"method", r.Method,
+			"pattern", plan.request.URI,
+			"request_id", string(RequestIDFromContext(r.Context())),
+			"module", plan.module,
+			"func", plan.request.Func,
+		),
+		w:     &recordingWriter{ResponseWriter: w},
+		body:  &countingReader{ReadCloser: r.Body},
+		start: time.Now(),
+	}
+	r = r.WithContext(context.WithValue(r.Context(), requestLogKey{}, l))
+	r.Body = l.body
+	return l.w, r
+}
+
+// logRequest logs a line of the request, with the status and duration if the response is written.
+// Responses with status 500 and above are logged as errors.
+func (c *Controller) logRequest(r *http.Request, msg string, args ...interface{}) {
+	c.logRequestAt(r, slog.LevelInfo, msg, args...)
+}
+
+// logRequestAt logs a line of the request at level, see logRequest.
+func (c *Controller) logRequestAt(r *http.Request, level slog.Level, msg string, args ...interface{}) {
+	l, ok := r.Context().Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		c.logger.Error(msg, append([]interface{}{
+			"method", r.Method,
+			"uri", r.URL.Path,
+			"request_id", string(RequestIDFromContext(r.Context())),
+		}, args...)...)
+		return
+	}
+
+	if l.w.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	if l.w.status != 0 {
+		args = append([]interface{}{"status", l.w.status, "duration", time.Since(l.start)}, args...)
+	}
+	l.logger.Log(r.Context(), level, msg, args...)
+}
+
+// recordingWriter records the status code and size of the response.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, e := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, e
+}
+
+// Flush supports streaming responses of module methods.
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type panicModule struct{}
+
+func (panicModule) Boom() (string, error) { panic("boom") }
+
+func TestSetLoggerKeepsComponent(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewController()
+	c.SetLogger(log.New(&buf, "app: ", log.LstdFlags))
+	c.AddModule(panicModule{})
+	c.Requests = []Request{{Name: "boom", Func: "Boom", Method: "GET", URI: "/boom"}}
+
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d", w.Code)
+	}
+
+	line := buf.String()
+	for _, want := range []string{"level=ERROR", `msg="module method panicked"`, "status=500", "component=go-api", "route=boom"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q does not contain %s", line, want)
+		}
+	}
+	if strings.Contains(line, "app: ") {
+		t.Errorf("log line %q contains the prefix of the log.Logger", line)
+	}
+}
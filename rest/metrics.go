@@ -0,0 +1,321 @@
+package rest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsContentType is the media type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram in seconds.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// sizeBuckets are the upper bounds of the body size histograms in bytes.
+var sizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+// MetricsSettings configures the metrics of the routes.
+//
+// The metrics are labelled by route name and method:
+//   - goapi_http_requests_total counts requests by status class, e.g. code="2xx"
+//   - goapi_http_request_duration_seconds is a histogram of the latency
+//   - goapi_http_requests_in_flight is the number of requests being handled
+//   - goapi_http_request_size_bytes and goapi_http_response_size_bytes are histograms of the body sizes
+//   - goapi_http_module_errors_total counts errors returned by module methods
+//
+// Only requests matching a route are counted, including requests rejected by route middleware.
+type MetricsSettings struct {
+	// Route is the path the metrics are served at in the Prometheus text format, e.g. '/metrics'.
+	// If empty, the metrics are only served by MetricsHandler.
+	Route string
+
+	// Buckets are the upper bounds of the latency histogram in seconds, DefaultLatencyBuckets if empty.
+	Buckets []float64
+}
+
+// SetMetrics enables the metrics of the routes. Must be called before Routes().
+func (c *Controller) SetMetrics(s MetricsSettings) {
+	if len(s.Buckets) == 0 {
+		s.Buckets = DefaultLatencyBuckets
+	}
+	c.metrics = &metrics{settings: s, routes: make(map[routeLabels]*routeMetrics)}
+}
+
+// MetricsHandler returns a handler serving the metrics in the Prometheus text format,
+// e.g. to serve them on a separate port. Metrics must be enabled with SetMetrics.
+func (c *Controller) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.metrics == nil {
+			http.Error(w, "metrics are not enabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", metricsContentType)
+		c.metrics.write(w)
+	})
+}
+
+// SetAccessLog enables a log line for every request handled by a route, with the fields of the route,
+// status, duration, body sizes and client address. See SetSlogLogger.
+func (c *Controller) SetAccessLog(enabled bool) {
+	c.accessLog = enabled
+}
+
+// planMetrics returns the metrics of the route of the plan, nil if metrics are disabled.
+func (c *Controller) planMetrics(plan *routePlan) *routeMetrics {
+	if c.metrics == nil {
+		return nil
+	}
+	return c.metrics.route(plan.request.Name, plan.request.Method)
+}
+
+// finishRequest records the metrics of the request and writes the access log.
+func (c *Controller) finishRequest(r *http.Request, rm *routeMetrics) {
+	l, ok := r.Context().Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+	status := l.w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if rm != nil {
+		atomic.AddInt64(&rm.inFlight, -1)
+		rm.observe(status, time.Since(l.start), l.body.n, l.w.bytes)
+	}
+
+	if c.accessLog {
+		c.logRequest(r, "request",
+			"uri", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+			"bytes_in", l.body.n,
+			"bytes_out", l.w.bytes,
+		)
+	}
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, e := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, e
+}
+
+// routeLabels identify the metrics of a route.
+type routeLabels struct {
+	route  string
+	method string
+}
+
+// metrics are the metrics of all routes, kept across hot reloads.
+type metrics struct {
+	settings MetricsSettings
+
+	mu     sync.Mutex
+	routes map[routeLabels]*routeMetrics
+}
+
+// route returns the metrics of the route, created on first use.
+func (m *metrics) route(name, method string) *routeMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := routeLabels{route: name, method: strings.ToUpper(method)}
+	rm, ok := m.routes[key]
+	if !ok {
+		rm = &routeMetrics{
+			requests:     make(map[string]uint64),
+			duration:     newHistogram(m.settings.Buckets),
+			requestSize:  newHistogram(sizeBuckets),
+			responseSize: newHistogram(sizeBuckets),
+		}
+		m.routes[key] = rm
+	}
+	return rm
+}
+
+// routeMetrics are the metrics of a single route.
+type routeMetrics struct {
+	inFlight int64 // accessed atomically
+
+	mu           sync.Mutex
+	requests     map[string]uint64 // by status class
+	moduleErrors uint64
+	duration     *histogram
+	requestSize  *histogram
+	responseSize *histogram
+}
+
+func (rm *routeMetrics) begin() {
+	atomic.AddInt64(&rm.inFlight, 1)
+}
+
+func (rm *routeMetrics) observe(status int, d time.Duration, in, out int64) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.requests[strconv.Itoa(status/100)+"xx"]++
+	rm.duration.observe(d.Seconds())
+	rm.requestSize.observe(float64(in))
+	rm.responseSize.observe(float64(out))
+}
+
+func (rm *routeMetrics) moduleError() {
+	rm.mu.Lock()
+	rm.moduleErrors++
+	rm.mu.Unlock()
+}
+
+// histogram counts observations in buckets with the upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write writes the metrics in the Prometheus text format, sorted by route and method.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	keys := make([]routeLabels, 0, len(m.routes))
+	routes := make(map[routeLabels]*routeMetrics, len(m.routes))
+	for key, rm := range m.routes {
+		keys = append(keys, key)
+		routes[key] = rm
+	}
+	m.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	// Copy the values, so that the lock is not held while writing
+	type snapshot struct {
+		labels       string
+		inFlight     int64
+		requests     map[string]uint64
+		moduleErrors uint64
+		duration     histogram
+		requestSize  histogram
+		responseSize histogram
+	}
+	snapshots := make([]snapshot, len(keys))
+	for i, key := range keys {
+		rm := routes[key]
+		rm.mu.Lock()
+		s := snapshot{
+			labels:       fmt.Sprintf(`route="%s",method="%s"`, escapeLabel(key.route), escapeLabel(key.method)),
+			inFlight:     atomic.LoadInt64(&rm.inFlight),
+			requests:     make(map[string]uint64, len(rm.requests)),
+			moduleErrors: rm.moduleErrors,
+			duration:     rm.duration.copy(),
+			requestSize:  rm.requestSize.copy(),
+			responseSize: rm.responseSize.copy(),
+		}
+		for class, n := range rm.requests {
+			s.requests[class] = n
+		}
+		rm.mu.Unlock()
+		snapshots[i] = s
+	}
+
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	writeHeader(b, "goapi_http_requests_total", "counter", "Requests handled by the route, by status class.")
+	for _, s := range snapshots {
+		classes := make([]string, 0, len(s.requests))
+		for class := range s.requests {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(b, "goapi_http_requests_total{%s,code=\"%s\"} %d\n", s.labels, class, s.requests[class])
+		}
+	}
+
+	writeHeader(b, "goapi_http_request_duration_seconds", "histogram", "Latency of the requests handled by the route.")
+	for _, s := range snapshots {
+		s.duration.write(b, "goapi_http_request_duration_seconds", s.labels)
+	}
+
+	writeHeader(b, "goapi_http_requests_in_flight", "gauge", "Requests currently handled by the route.")
+	for _, s := range snapshots {
+		fmt.Fprintf(b, "goapi_http_requests_in_flight{%s} %d\n", s.labels, s.inFlight)
+	}
+
+	writeHeader(b, "goapi_http_request_size_bytes", "histogram", "Size of the request bodies read by the route.")
+	for _, s := range snapshots {
+		s.requestSize.write(b, "goapi_http_request_size_bytes", s.labels)
+	}
+
+	writeHeader(b, "goapi_http_response_size_bytes", "histogram", "Size of the response bodies written by the route.")
+	for _, s := range snapshots {
+		s.responseSize.write(b, "goapi_http_response_size_bytes", s.labels)
+	}
+
+	writeHeader(b, "goapi_http_module_errors_total", "counter", "Errors returned by the module method of the route.")
+	for _, s := range snapshots {
+		fmt.Fprintf(b, "goapi_http_module_errors_total{%s} %d\n", s.labels, s.moduleErrors)
+	}
+}
+
+func (h *histogram) copy() histogram {
+	c := *h
+	c.counts = append([]uint64{}, h.counts...)
+	return c
+}
+
+func (h *histogram) write(w io.Writer, name, labels string) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, promFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, promFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value of the Prometheus text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
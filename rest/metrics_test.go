@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type guardedModule struct{}
+
+func (guardedModule) Secret() (string, error) { return "secret", nil }
+
+func TestMiddlewareRejectionIsInstrumented(t *testing.T) {
+	var logs bytes.Buffer
+	c := NewController()
+	c.SetLogHandler(slog.NewTextHandler(&logs, nil))
+	c.SetAccessLog(true)
+	c.SetMetrics(MetricsSettings{})
+	c.AddModule(guardedModule{})
+	c.AddMiddleware("auth", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if RequestIDFromContext(r.Context()) == "" {
+				t.Error("middleware runs without request ID")
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		})
+	})
+	c.Requests = []Request{{Name: "secret", Func: "Secret", Method: "GET", URI: "/secret", Middleware: []string{"auth"}}}
+
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/secret", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d", w.Code)
+	}
+	if w.Header().Get(DefaultRequestIDHeader) == "" {
+		t.Error("no request ID header")
+	}
+	if !strings.Contains(logs.String(), "msg=request") || !strings.Contains(logs.String(), "status=401") {
+		t.Errorf("no access log line, got %q", logs.String())
+	}
+
+	metrics := httptest.NewRecorder()
+	c.MetricsHandler().ServeHTTP(metrics, httptest.NewRequest("GET", "/metrics", nil))
+	want := `goapi_http_requests_total{route="secret",method="GET",code="4xx"} 1`
+	if !strings.Contains(metrics.Body.String(), want) {
+		t.Errorf("metrics do not contain %s:\n%s", want, metrics.Body.String())
+	}
+}
@@ -0,0 +1,437 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxFormValueSize limits the size of a single text value of a multipart form.
+const maxFormValueSize = 10 << 20
+
+// maxFormMemory limits the total size of the text values of a multipart form without MaxTotalSize.
+const maxFormMemory = 32 << 20
+
+var (
+	fileInfoPtrType   = reflect.TypeOf(&FileInfo{})
+	fileInfoSliceType = reflect.TypeOf([]*FileInfo{})
+	stringSliceType   = reflect.TypeOf([]string{})
+)
+
+// ByteSize is a size in bytes. In the configuration it is a number of bytes
+// or a number with one of the units KB, MB or GB, e.g. '10MB'. Units are powers of 1024.
+type ByteSize int64
+
+// UnmarshalYAML accepts a number of bytes or a size with unit.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if e := unmarshal(&s); e != nil {
+		return e
+	}
+	return b.parse(s)
+}
+
+// UnmarshalJSON accepts a number of bytes or a size with unit.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var s string
+	if e := json.Unmarshal(data, &s); e != nil {
+		var n int64
+		if e := json.Unmarshal(data, &n); e != nil {
+			return fmt.Errorf("invalid size %s", data)
+		}
+		*b = ByteSize(n)
+		return nil
+	}
+	return b.parse(s)
+}
+
+func (b *ByteSize) parse(s string) error {
+	units := []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	s = strings.TrimSpace(strings.ToUpper(s))
+	factor := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, factor = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.factor
+			break
+		}
+	}
+	n, e := strconv.ParseInt(s, 10, 64)
+	if e != nil || n < 0 {
+		return fmt.Errorf("invalid size '%s'", s)
+	}
+	*b = ByteSize(n * factor)
+	return nil
+}
+
+// formData holds the values and files of a multipart form.
+type formData struct {
+	values map[string][]string
+	files  map[string][]*FileInfo
+}
+
+// remove deletes the temporary files of the form.
+func (f *formData) remove() {
+	for _, files := range f.files {
+		for _, fi := range files {
+			os.Remove(fi.Path)
+		}
+	}
+}
+
+// multipartParser streams the multipart form of a request once, for all form binders of a route.
+//
+// Files are written to temporary files while their size and SHA-256 digest are computed.
+// The temporary files are removed after the module method returns.
+// Text values are kept in memory, values of undeclared fields are discarded.
+type multipartParser struct {
+	files        map[string]*fileRules
+	values       map[string]bool
+	scanners     []FileScanner
+	maxFileSize  int64
+	maxTotalSize int64
+	maxFiles     int
+}
+
+func newMultipartParser(body BodyType, scanners []FileScanner) (*multipartParser, error) {
+	p := &multipartParser{
+		files:        make(map[string]*fileRules, len(body.Forms)),
+		values:       make(map[string]bool),
+		scanners:     scanners,
+		maxFileSize:  int64(body.MaxFileSize),
+		maxTotalSize: int64(body.MaxTotalSize),
+		maxFiles:     body.MaxFiles,
+	}
+	for _, form := range body.Forms {
+		if !form.IsFile {
+			if len(form.AllowedTypes) > 0 || len(form.AllowedExtensions) > 0 || form.OnMismatch != "" {
+				return nil, fmt.Errorf("form '%s': upload rules require a file", form.Name)
+			}
+			if form.StructName == "" {
+				p.values[form.Name] = true
+			}
+			continue
+		}
+		rules, e := newFileRules(form)
+		if e != nil {
+			return nil, e
+		}
+		p.files[form.Name] = rules
+	}
+	return p, nil
+}
+
+// form returns the form of the request, parsed on first use.
+func (p *multipartParser) form(s *callState) (*formData, error) {
+	if s.form == nil && s.formErr == nil {
+		s.form, s.formErr = p.parse(s.r)
+		if s.formErr != nil && s.form != nil {
+			s.form.remove()
+			s.form = nil
+		}
+	}
+	return s.form, s.formErr
+}
+
+func (p *multipartParser) parse(r *http.Request) (*formData, error) {
+	reader, e := r.MultipartReader()
+	if e == http.ErrNotMultipart {
+		return nil, NewError(http.StatusUnsupportedMediaType, "expected a multipart/form-data body")
+	}
+	if e != nil {
+		return nil, BadRequest("could not parse multipart form: %v", e)
+	}
+
+	form := &formData{
+		values: make(map[string][]string),
+		files:  make(map[string][]*FileInfo),
+	}
+	total, memory, fileCount := int64(0), int64(0), 0
+
+	for {
+		part, e := reader.NextPart()
+		if e == io.EOF {
+			return form, nil
+		}
+		if e != nil {
+			return form, BadRequest("could not parse multipart form: %v", e)
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		// Parts are limited by the remaining total size, one more byte detects an oversized form
+		limit := int64(-1)
+		if p.maxTotalSize > 0 {
+			limit = p.maxTotalSize - total
+		}
+
+		isFile := part.FileName() != ""
+		rules, ok := p.files[name]
+		if isFile && !ok || !isFile && !p.values[name] {
+			// Undeclared fields are skipped, but count to the total size
+			n, e := io.Copy(ioutil.Discard, limitReader(part, limit))
+			part.Close()
+			if e != nil {
+				return form, BadRequest("could not read multipart form: %v", e)
+			}
+			if total += n; limit >= 0 && n > limit {
+				return form, p.totalSizeError()
+			}
+			continue
+		}
+
+		if !isFile {
+			// Without a total size, the values kept in memory are limited to maxFormMemory
+			valueLimit := int64(maxFormValueSize)
+			if limit >= 0 && limit < valueLimit {
+				valueLimit = limit
+			}
+			if p.maxTotalSize <= 0 && maxFormMemory-memory < valueLimit {
+				valueLimit = maxFormMemory - memory
+			}
+			value, e := ioutil.ReadAll(limitReader(part, valueLimit))
+			part.Close()
+			if e != nil {
+				return form, BadRequest("could not read multipart form: %v", e)
+			}
+			if int64(len(value)) > valueLimit {
+				switch valueLimit {
+				case limit:
+					return form, p.totalSizeError()
+				case maxFormMemory - memory:
+					return form, NewError(http.StatusRequestEntityTooLarge,
+						"form values exceed the maximum size of %d bytes in total", maxFormMemory)
+				}
+				return form, NewError(http.StatusRequestEntityTooLarge,
+					"form value '%s' exceeds the maximum size of %d bytes", name, valueLimit)
+			}
+			total += int64(len(value))
+			memory += int64(len(value))
+			form.values[name] = append(form.values[name], string(value))
+			continue
+		}
+
+		fileCount++
+		if p.maxFiles > 0 && fileCount > p.maxFiles {
+			part.Close()
+			return form, BadRequest("too many files, at most %d are allowed", p.maxFiles)
+		}
+
+		if e := rules.checkName(part.FileName()); e != nil {
+			part.Close()
+			return form, e
+		}
+
+		fi, e := p.saveFile(part, limit, rules)
+		part.Close()
+		if fi != nil {
+			form.files[name] = append(form.files[name], fi)
+		}
+		if e != nil {
+			return form, e
+		}
+		total += fi.FileSize
+
+		if e := scanFile(r.Context(), p.scanners, fi); e != nil {
+			return form, e
+		}
+	}
+}
+
+// saveFile streams the content of a file part into a temporary file.
+// The content type is checked against the rules before the file is read completely.
+func (p *multipartParser) saveFile(part *multipart.Part, totalLimit int64, rules *fileRules) (*FileInfo, error) {
+	limit := totalLimit
+	if p.maxFileSize > 0 && (limit < 0 || p.maxFileSize < limit) {
+		limit = p.maxFileSize
+	}
+
+	file, e := ioutil.TempFile("", "go-api-upload-")
+	if e != nil {
+		return nil, fmt.Errorf("could not create temporary file: %v", e)
+	}
+	defer file.Close()
+	fi := &FileInfo{FileName: part.FileName(), Path: file.Name()}
+
+	// The first bytes are kept to detect the content type
+	reader := limitReader(part, limit)
+	head := make([]byte, 512)
+	n, e := io.ReadFull(reader, head)
+	if e != nil && e != io.EOF && e != io.ErrUnexpectedEOF {
+		return fi, BadRequest("could not read multipart form: %v", e)
+	}
+	head = head[:n]
+	fi.ContentType = http.DetectContentType(head)
+	if e := rules.checkContent(fi); e != nil {
+		return fi, e
+	}
+
+	hash := sha256.New()
+	w := io.MultiWriter(file, hash)
+	if _, e := w.Write(head); e != nil {
+		return fi, fmt.Errorf("could not write temporary file: %v", e)
+	}
+	copied, e := io.Copy(w, reader)
+	if e != nil {
+		return fi, BadRequest("could not read multipart form: %v", e)
+	}
+
+	fi.FileSize = int64(n) + copied
+	fi.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if limit >= 0 && fi.FileSize > limit {
+		if limit == p.maxFileSize {
+			return fi, NewError(http.StatusRequestEntityTooLarge,
+				"file '%s' exceeds the maximum size of %d bytes", fi.FileName, p.maxFileSize)
+		}
+		return fi, p.totalSizeError()
+	}
+	return fi, nil
+}
+
+func (p *multipartParser) totalSizeError() error {
+	return NewError(http.StatusRequestEntityTooLarge,
+		"multipart form exceeds the maximum size of %d bytes", p.maxTotalSize)
+}
+
+// limitReader reads at most limit + 1 bytes, to detect content exceeding the limit.
+// A negative limit does not limit the reader.
+func limitReader(r io.Reader, limit int64) io.Reader {
+	if limit < 0 {
+		return r
+	}
+	return io.LimitReader(r, limit+1)
+}
+
+// multipartBinder returns the binder of a single form field.
+//
+// Files are passed as *FileInfo, text values as string.
+// Fields with multiple set are passed as []*FileInfo or []string.
+// A form with StructName passes all text values as struct, see formStructBinder.
+func multipartBinder(form MultipartForm, parser *multipartParser) (argBinder, error) {
+	if form.StructName != "" {
+		if form.IsFile {
+			return argBinder{}, fmt.Errorf("form struct '%s' can not be a file", form.StructName)
+		}
+		return formStructBinder(form.StructName, parser)
+	}
+
+	switch {
+	case form.IsFile && form.Multiple:
+		return argBinder{
+			typ: fileInfoSliceType,
+			bind: func(s *callState) (reflect.Value, error) {
+				data, e := parser.form(s)
+				if e != nil {
+					return reflect.Value{}, e
+				}
+				files := data.files[form.Name]
+				if files == nil {
+					files = []*FileInfo{}
+				}
+				return reflect.ValueOf(files), nil
+			},
+		}, nil
+	case form.IsFile:
+		return argBinder{
+			typ: fileInfoPtrType,
+			bind: func(s *callState) (reflect.Value, error) {
+				data, e := parser.form(s)
+				if e != nil {
+					return reflect.Value{}, e
+				}
+				files := data.files[form.Name]
+				if len(files) == 0 {
+					return reflect.Value{}, BadRequest("missing file '%s'", form.Name)
+				}
+				return reflect.ValueOf(files[0]), nil
+			},
+		}, nil
+	case form.Multiple:
+		return argBinder{
+			typ: stringSliceType,
+			bind: func(s *callState) (reflect.Value, error) {
+				data, e := parser.form(s)
+				if e != nil {
+					return reflect.Value{}, e
+				}
+				values := data.values[form.Name]
+				if values == nil {
+					values = []string{}
+				}
+				return reflect.ValueOf(values), nil
+			},
+		}, nil
+	default:
+		return argBinder{
+			typ: reflect.TypeOf(""),
+			bind: func(s *callState) (reflect.Value, error) {
+				data, e := parser.form(s)
+				if e != nil {
+					return reflect.Value{}, e
+				}
+				value := ""
+				if values := data.values[form.Name]; len(values) > 0 {
+					value = values[0]
+				}
+				return reflect.ValueOf(value), nil
+			},
+		}, nil
+	}
+}
+
+// formStructBinder binds the text values of the form into the fields of a registered struct.
+//
+// The form field name of a struct field is taken from its 'form' tag, its 'json' tag or the field name.
+// The values are converted like query parameters and checked against the 'validate' tags of the fields.
+// Invalid values and rule violations of all fields are rejected together with status 422.
+func formStructBinder(structName string, parser *multipartParser) (argBinder, error) {
+	t := TypeRegistry[structName]
+	if t == nil {
+		return argBinder{}, fmt.Errorf("form struct type '%s' not found in type registry", structName)
+	}
+	if t.Kind() != reflect.Struct {
+		return argBinder{}, fmt.Errorf("form struct type '%s' is not a struct", structName)
+	}
+
+	fields, e := structValueBinders(t, "form", func(name string) QueryParam {
+		return QueryParam{}
+	})
+	if e != nil {
+		return argBinder{}, fmt.Errorf("form struct type '%s': %v", structName, e)
+	}
+	for _, f := range fields {
+		parser.values[f.name] = true
+	}
+
+	return argBinder{
+		typ: t,
+		bind: func(s *callState) (reflect.Value, error) {
+			data, e := parser.form(s)
+			if e != nil {
+				return reflect.Value{}, e
+			}
+			v, fieldErrors, violations := bindStruct(t, fields, data.values)
+			if e := validationError(append(fieldErrors, violations...)); e != nil {
+				return reflect.Value{}, e
+			}
+			return v, nil
+		},
+	}, nil
+}
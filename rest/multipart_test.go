@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type formNote struct {
+	Title string `form:"title"`
+}
+
+func multipartRequest(t *testing.T, values map[string][]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, list := range values {
+		for _, v := range list {
+			if e := mw.WriteField(name, v); e != nil {
+				t.Fatal(e)
+			}
+		}
+	}
+	if e := mw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	r := httptest.NewRequest("POST", "/notes", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestMultipartDiscardsUndeclaredValues(t *testing.T) {
+	AddTypeToRegistry(formNote{})
+	p, e := newMultipartParser(BodyType{IsMultipart: true, Forms: []MultipartForm{{Name: "tag", Multiple: true}}}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := formStructBinder("rest.formNote", p); e != nil {
+		t.Fatal(e)
+	}
+
+	form, e := p.parse(multipartRequest(t, map[string][]string{
+		"tag":   {"a", "b"},
+		"title": {"note"},
+		"other": {strings.Repeat("x", 1000)},
+	}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(form.values["tag"]) != 2 || len(form.values["title"]) != 1 {
+		t.Errorf("declared values missing: %v", form.values)
+	}
+	if _, ok := form.values["other"]; ok {
+		t.Errorf("undeclared value kept")
+	}
+}
+
+func TestMultipartMemoryLimit(t *testing.T) {
+	p, e := newMultipartParser(BodyType{IsMultipart: true, Forms: []MultipartForm{{Name: "tag", Multiple: true}}}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	value := strings.Repeat("x", 9<<20)
+	_, e = p.parse(multipartRequest(t, map[string][]string{"tag": {value, value, value, value}}))
+	if status := errorStatus(e); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, error %v", status, e)
+	}
+}
+
+func errorStatus(e error) int {
+	if he, ok := e.(HTTPStatusCoder); ok {
+		return he.HTTPStatus()
+	}
+	return 0
+}
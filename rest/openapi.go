@@ -0,0 +1,510 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/benschs/go-api/openapi"
+)
+
+// OpenAPISettings configures the OpenAPI document generated by the Controller.
+type OpenAPISettings struct {
+	// Route is the path the document is served at.
+	// The JSON document is served at Route + ".json", the YAML document at Route + ".yaml".
+	// If empty, the document is not served.
+	Route string
+
+	Title       string
+	Version     string
+	Description string
+}
+
+// SetOpenAPI changes the settings of the generated OpenAPI document.
+// Must be called before Routes() to serve the document.
+func (c *Controller) SetOpenAPI(s OpenAPISettings) {
+	c.openAPI = s
+}
+
+// OpenAPI generates an OpenAPI 3.1 document describing the routes of the Controller.
+//
+// Parameters are taken from the route configuration, request bodies from the TypeRegistry
+// and response schemas from the return types of the module methods.
+func (c *Controller) OpenAPI() (*openapi.Document, error) {
+	return c.openAPIDocument(c.currentRequests())
+}
+
+// openAPIDocument generates the OpenAPI document of the requests.
+func (c *Controller) openAPIDocument(requests []Request) (*openapi.Document, error) {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       c.openAPI.Title,
+			Version:     c.openAPI.Version,
+			Description: c.openAPI.Description,
+		},
+		Paths: make(map[string]*openapi.PathItem),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+
+	sb := newSchemaBuilder()
+
+	for _, rqst := range requests {
+		op, e := c.openAPIOperation(rqst, sb)
+		if e != nil {
+			return nil, fmt.Errorf("route '%s': %v", rqst.Name, e)
+		}
+
+		path := openAPIPath(rqst.URI)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		if e := item.SetOperation(rqst.Method, op); e != nil {
+			return nil, fmt.Errorf("route '%s': %v", rqst.Name, e)
+		}
+	}
+
+	if len(sb.components) > 0 {
+		doc.Components = &openapi.Components{Schemas: sb.components}
+	}
+
+	return doc, nil
+}
+
+func (c *Controller) openAPIOperation(rqst Request, sb *schemaBuilder) (*openapi.Operation, error) {
+	op := &openapi.Operation{
+		OperationID: operationID(rqst),
+		Summary:     rqst.Name,
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	for _, name := range rqst.Headers {
+		// OpenAPI ignores header parameters with these names
+		switch http.CanonicalHeaderKey(name) {
+		case "Accept", "Content-Type", "Authorization":
+			continue
+		}
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:   name,
+			In:     "header",
+			Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+
+	uriPatterns := uriParams(rqst.URI)
+	for _, p := range rqst.Params {
+		t, e := p.Type.goType()
+		if e != nil {
+			return nil, fmt.Errorf("URL parameter '%s': %v", p.Name, e)
+		}
+		schema := sb.paramSchema(t, "")
+		switch {
+		case p.Type == UUID:
+			schema.Format = "uuid"
+		case p.Pattern != "":
+			schema.Pattern = p.Pattern
+		case uriPatterns[p.Name] != "" && t.Kind() == reflect.String:
+			schema.Pattern = uriPatterns[p.Name]
+		}
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:     p.Name,
+			In:       "path",
+			Required: true,
+			Schema:   schema,
+		})
+	}
+
+	queryParams, e := openAPIQueryParams(rqst, sb)
+	if e != nil {
+		return nil, e
+	}
+	op.Parameters = append(op.Parameters, queryParams...)
+
+	if rqst.Body.IsJSON {
+		t := TypeRegistry[rqst.Body.JSONStructName]
+		if t == nil {
+			return nil, fmt.Errorf("json body type '%s' not found in type registry", rqst.Body.JSONStructName)
+		}
+		consumes, e := c.routeCodecs(rqst.Consumes)
+		if e != nil {
+			return nil, e
+		}
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  openAPIContent(consumes, sb.schema(t)),
+		}
+	}
+
+	if rqst.Body.IsMultipart {
+		form := &openapi.Schema{Type: "object", Properties: make(map[string]*openapi.Schema)}
+		for _, f := range rqst.Body.Forms {
+			if f.StructName != "" {
+				if e := sb.addFormStruct(form, f.StructName); e != nil {
+					return nil, e
+				}
+				continue
+			}
+			property := &openapi.Schema{Type: "string"}
+			if f.IsFile {
+				property.Format = "binary"
+			}
+			if f.Multiple {
+				property = &openapi.Schema{Type: "array", Items: property}
+			}
+			form.Properties[f.Name] = property
+		}
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				"multipart/form-data": {Schema: form},
+			},
+		}
+	}
+
+	fnValue, e := c.resolveMethod(rqst)
+	if e != nil {
+		return nil, e
+	}
+	status := rqst.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// The body of a Response or of a method writing the response itself is not known before the call
+	response := &openapi.Response{Description: "successful response"}
+	if fnType := fnValue.Type(); fnType.NumOut() > 1 && !isResponseType(fnType.Out(0)) &&
+		status != http.StatusNoContent {
+		produces := []mediaCodec{{mediaType: MediaTypeJSON}}
+		if c.rw == nil {
+			if produces, e = c.routeCodecs(rqst.Produces); e != nil {
+				return nil, e
+			}
+		}
+		response.Content = openAPIContent(produces, sb.schema(fnType.Out(0)))
+	}
+	op.Responses[strconv.Itoa(status)] = response
+	op.Responses["default"] = &openapi.Response{
+		Description: "error response",
+		Content: map[string]*openapi.MediaType{
+			ProblemContentType: {Schema: sb.schema(problemType)},
+		},
+	}
+
+	return op, nil
+}
+
+// openAPIQueryParams describes the query parameters of the request.
+func openAPIQueryParams(rqst Request, sb *schemaBuilder) ([]*openapi.Parameter, error) {
+	declared := make(map[string]QueryParam, len(rqst.Query))
+	for _, q := range rqst.Query {
+		declared[q.Name] = q
+	}
+
+	params := []*openapi.Parameter{}
+	if rqst.QueryStruct != "" {
+		t := TypeRegistry[rqst.QueryStruct]
+		if t == nil || t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("query struct type '%s' not found in type registry", rqst.QueryStruct)
+		}
+		fields, e := structValueBinders(t, "query", func(name string) QueryParam {
+			return declared[name]
+		})
+		if e != nil {
+			return nil, e
+		}
+		for _, f := range fields {
+			params = append(params, &openapi.Parameter{
+				Name:     f.name,
+				In:       "query",
+				Required: f.required,
+				Schema:   sb.paramSchema(f.typ, declared[f.name].Default),
+			})
+		}
+		return params, nil
+	}
+
+	for _, q := range rqst.Query {
+		t, e := q.Type.goType()
+		if e != nil {
+			return nil, fmt.Errorf("query parameter '%s': %v", q.Name, e)
+		}
+		params = append(params, &openapi.Parameter{
+			Name:     q.Name,
+			In:       "query",
+			Required: q.Required,
+			Schema:   sb.paramSchema(t, q.Default),
+		})
+	}
+	return params, nil
+}
+
+// serveOpenAPI returns a handler writing the OpenAPI document of the requests as JSON or YAML.
+func (c *Controller) serveOpenAPI(requests []Request, asYAML bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, e := c.openAPIDocument(requests)
+		if e != nil {
+			c.internalError(w, r, e)
+			return
+		}
+
+		var data []byte
+		if asYAML {
+			w.Header().Set("Content-Type", "application/yaml")
+			data, e = yaml.Marshal(doc)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			data, e = json.Marshal(doc)
+		}
+		if e != nil {
+			c.internalError(w, r, e)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// openAPIContent returns the content of a request or response body with the schema for each media type.
+func openAPIContent(codecs []mediaCodec, schema *openapi.Schema) map[string]*openapi.MediaType {
+	content := make(map[string]*openapi.MediaType, len(codecs))
+	for _, mc := range codecs {
+		content[mc.mediaType] = &openapi.MediaType{Schema: schema}
+	}
+	return content
+}
+
+// openAPIPath converts a chi route pattern to an OpenAPI path by removing
+// regular expressions from URL parameters, e.g. '/users/{id:[0-9]+}' to '/users/{id}'.
+func openAPIPath(pattern string) string {
+	var b strings.Builder
+	depth := 0
+	skip := false
+	for _, r := range pattern {
+		switch {
+		case r == '{':
+			depth++
+			if depth == 1 {
+				b.WriteRune(r)
+				continue
+			}
+		case r == '}':
+			depth--
+			if depth == 0 {
+				skip = false
+				b.WriteRune(r)
+				continue
+			}
+		case r == ':' && depth == 1:
+			skip = true
+		}
+		if !skip {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// operationID creates a camel case operation id from the request name,
+// e.g. 'list messages' to 'listMessages'.
+func operationID(rqst Request) string {
+	words := strings.FieldsFunc(rqst.Name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return rqst.Func
+	}
+
+	var b strings.Builder
+	for i, w := range words {
+		if i == 0 {
+			b.WriteString(strings.ToLower(w[:1]))
+		} else {
+			b.WriteString(strings.ToUpper(w[:1]))
+		}
+		b.WriteString(w[1:])
+	}
+	return b.String()
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	fileInfoType = reflect.TypeOf(FileInfo{})
+	problemType  = reflect.TypeOf(Problem{})
+)
+
+// schemaBuilder creates JSON schemas from Go types.
+// Named struct types are added to the components and referenced.
+type schemaBuilder struct {
+	components map[string]*openapi.Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: make(map[string]*openapi.Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// addFormStruct adds the fields of a form struct as properties of the form schema.
+func (sb *schemaBuilder) addFormStruct(form *openapi.Schema, structName string) error {
+	t := TypeRegistry[structName]
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("form struct type '%s' not found in type registry", structName)
+	}
+	fields, e := structValueBinders(t, "form", func(name string) QueryParam {
+		return QueryParam{}
+	})
+	if e != nil {
+		return e
+	}
+	for _, f := range fields {
+		form.Properties[f.name] = sb.paramSchema(f.typ, "")
+	}
+	return nil
+}
+
+func (sb *schemaBuilder) schema(t reflect.Type) *openapi.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &openapi.Schema{Type: "string", Format: "date-time"}
+	case fileInfoType:
+		return &openapi.Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openapi.Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &openapi.Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		min := 0.0
+		return &openapi.Schema{Type: "integer", Minimum: &min}
+	case reflect.Float32:
+		return &openapi.Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openapi.Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openapi.Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openapi.Schema{Type: "string", Format: "byte"}
+		}
+		return &openapi.Schema{Type: "array", Items: sb.schema(t.Elem())}
+	case reflect.Map:
+		return &openapi.Schema{Type: "object", AdditionalProperties: sb.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sb.structSchema(t)
+		}
+		name := sb.componentName(t)
+		if _, ok := sb.components[name]; !ok {
+			// Register before building to allow recursive types
+			sb.components[name] = nil
+			sb.components[name] = sb.structSchema(t)
+		}
+		return openapi.RefTo(name)
+	default:
+		// Interfaces and other types may hold any value
+		return &openapi.Schema{}
+	}
+}
+
+// paramSchema returns the schema of a parameter converted from its string representation.
+func (sb *schemaBuilder) paramSchema(t reflect.Type, defaultValue string) *openapi.Schema {
+	if t.Kind() == reflect.Slice {
+		return &openapi.Schema{Type: "array", Items: sb.paramSchema(t.Elem(), "")}
+	}
+	s := sb.schema(t)
+	if t == durationType {
+		s = &openapi.Schema{Type: "string", Format: "duration"}
+	}
+	if defaultValue == "" {
+		return s
+	}
+	s.Default = defaultValue
+	if v, e := parseScalar(defaultValue, t); e == nil {
+		switch t.Kind() {
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			s.Default = v.Interface()
+		}
+	}
+	return s
+}
+
+func (sb *schemaBuilder) structSchema(t reflect.Type) *openapi.Schema {
+	s := &openapi.Schema{Type: "object", Properties: make(map[string]*openapi.Schema)}
+	sb.addFields(s, t)
+	return s
+}
+
+// addFields adds the fields decoded from JSON as properties, see jsonFields.
+// Fields with the ',string' option are strings and fields with the 'required' validation rule are required,
+// as the body is decoded and validated.
+func (sb *schemaBuilder) addFields(s *openapi.Schema, t reflect.Type) {
+	for _, f := range jsonFields(t) {
+		if f.quoted {
+			s.Properties[f.name] = &openapi.Schema{Type: "string"}
+		} else {
+			s.Properties[f.name] = sb.schema(f.typ)
+		}
+		if hasRule(t.FieldByIndex(f.index).Tag.Get("validate"), "required") {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+}
+
+// componentName returns the component key of the type. Types with the same name in different packages,
+// e.g. a.User and b/a.User, are told apart by the package path of the types added later.
+func (sb *schemaBuilder) componentName(t reflect.Type) string {
+	if name, ok := sb.names[t]; ok {
+		return name
+	}
+	name := componentName(t)
+	if _, taken := sb.components[name]; taken {
+		name = componentKey(t.PkgPath() + "." + t.Name())
+	}
+	for base, i := name, 2; ; i++ {
+		if _, taken := sb.components[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	sb.names[t] = name
+	return name
+}
+
+// componentName returns a name for the type that is valid as component key.
+func componentName(t reflect.Type) string {
+	return componentKey(t.String())
+}
+
+func componentKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	htmltemplate "html/template"
+	"reflect"
+	"testing"
+	texttemplate "text/template"
+
+	"github.com/benschs/go-api/openapi"
+)
+
+type orderBase struct {
+	ID string `json:"id" validate:"required,uuid"`
+}
+
+type orderInput struct {
+	orderBase
+	Quantity int64  `json:"quantity,string" validate:"min=1"`
+	Customer string `json:"customer" validate:"required"`
+	Note     string `json:"note,omitempty"`
+	Internal string `json:"-"`
+}
+
+type orderModule struct{}
+
+func (orderModule) Create(o orderInput) (orderInput, error) { return o, nil }
+
+func TestOpenAPIStructSchema(t *testing.T) {
+	c := NewController()
+	c.AddModule(orderModule{})
+	c.Requests = []Request{{
+		Name: "create", Func: "Create", Method: "POST", URI: "/orders",
+		Body: JSONBody[orderInput](),
+	}}
+
+	doc, e := c.OpenAPI()
+	if e != nil {
+		t.Fatal(e)
+	}
+	s := doc.Components.Schemas[componentName(reflect.TypeOf(orderInput{}))]
+	if s == nil {
+		t.Fatalf("no component schema, got %v", doc.Components.Schemas)
+	}
+
+	props := make(map[string]string)
+	for name, p := range s.Properties {
+		props[name] = p.Type
+	}
+	want := map[string]string{"id": "string", "quantity": "string", "customer": "string", "note": "string"}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("property types %v, want %v", props, want)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"id", "customer"}) {
+		t.Errorf("required %v, want [id customer]", s.Required)
+	}
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	input := reflect.TypeOf(orderInput{})
+	// A type declared in a function has the same name as the type of the package
+	type orderInput struct {
+		Local bool `json:"local"`
+	}
+	local := reflect.TypeOf(orderInput{})
+	qualified := componentKey(local.PkgPath() + ".orderInput")
+
+	sb := newSchemaBuilder()
+	types := []reflect.Type{
+		reflect.TypeOf(texttemplate.Template{}),
+		reflect.TypeOf(htmltemplate.Template{}),
+		input,
+		local,
+		reflect.TypeOf(htmltemplate.Template{}),
+		input,
+	}
+	refs := make([]string, len(types))
+	for i, typ := range types {
+		refs[i] = sb.schema(typ).Ref
+	}
+
+	want := []string{
+		openapi.RefTo("template.Template").Ref,
+		openapi.RefTo("html_template.Template").Ref,
+		openapi.RefTo("rest.orderInput").Ref,
+		openapi.RefTo(qualified).Ref,
+		openapi.RefTo("html_template.Template").Ref,
+		openapi.RefTo("rest.orderInput").Ref,
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs %v, want %v", refs, want)
+	}
+	if s := sb.components[qualified]; s == nil || s.Properties["local"] == nil {
+		t.Errorf("schema of the local type %+v", s)
+	}
+	if s := sb.components["rest.orderInput"]; s == nil || s.Properties["customer"] == nil {
+		t.Errorf("schema of the package type %+v", s)
+	}
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// PanicError is a panic of a module method, recovered by the Controller.
+// Value is the value passed to panic and Stack the stack trace of the panicking goroutine.
+type PanicError struct {
+	Route string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in route '%s': %v", e.Route, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// PanicHook is called with every recovered panic, e.g. to forward it to a crash reporter.
+type PanicHook func(r *http.Request, e *PanicError)
+
+// OnPanic sets a hook called when a module method panics, after the 500 response is written
+// and the panic is logged.
+//
+// The hook runs in the handler of the request. The response is flushed before, so that the client
+// receives the status and body, but the request is only finished when the hook returns.
+// Slow crash reporters should send the panic in their own goroutine.
+func (c *Controller) OnPanic(hook PanicHook) {
+	c.panicHook = hook
+}
+
+// recovered responds with status 500, logs the panic with the status and calls the panic hook.
+func (c *Controller) recovered(w http.ResponseWriter, r *http.Request, e *PanicError) {
+	c.writeError(w, r, Internal(e))
+	c.logRequestAt(r, slog.LevelError, "module method panicked", "panic", fmt.Sprint(e.Value), "stack", string(e.Stack))
+	if c.panicHook != nil {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		c.panicHook(r, e)
+	}
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPanicHookAfterResponse(t *testing.T) {
+	var logs bytes.Buffer
+	c := NewController()
+	c.SetLogHandler(slog.NewTextHandler(&logs, nil))
+	c.AddModule(panicModule{})
+	c.Requests = []Request{{Name: "boom", Func: "Boom", Method: "GET", URI: "/boom"}}
+
+	w := httptest.NewRecorder()
+	var hooked *PanicError
+	c.OnPanic(func(r *http.Request, e *PanicError) {
+		if w.Code != http.StatusInternalServerError || w.Body.Len() == 0 {
+			t.Errorf("hook called before the response is written")
+		}
+		if !w.Flushed {
+			t.Errorf("hook called before the response is flushed")
+		}
+		if !strings.Contains(logs.String(), "module method panicked") {
+			t.Errorf("hook called before the panic is logged")
+		}
+		hooked = e
+	})
+	c.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+
+	if hooked == nil {
+		t.Fatal("hook not called")
+	}
+	if hooked.Route != "boom" || hooked.Value != "boom" || len(hooked.Stack) == 0 {
+		t.Errorf("panic error %+v", hooked)
+	}
+}
+
+// TestPanicHookSlow checks that the client receives the response while the hook is running.
+func TestPanicHookSlow(t *testing.T) {
+	c := NewController()
+	c.AddModule(panicModule{})
+	c.Requests = []Request{{Name: "boom", Func: "Boom", Method: "GET", URI: "/boom"}}
+
+	release := make(chan struct{})
+	c.OnPanic(func(r *http.Request, e *PanicError) {
+		<-release
+	})
+	server := httptest.NewServer(c.Routes())
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, e := client.Get(server.URL + "/boom")
+	if e != nil {
+		t.Fatalf("response not received while the hook runs: %v", e)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status %d", resp.StatusCode)
+	}
+}
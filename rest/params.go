@@ -0,0 +1,653 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/go-chi/chi"
+)
+
+// ParamType names the type of a parameter in the route configuration.
+type ParamType string
+
+// Parameter types supported in the route configuration.
+// List types bind repeated keys, e.g. '?id=1&id=2'.
+const (
+	String   ParamType = "string"
+	Int      ParamType = "int"
+	Int64    ParamType = "int64"
+	Uint     ParamType = "uint"
+	Float    ParamType = "float"
+	Bool     ParamType = "bool"
+	Time     ParamType = "time"     // RFC 3339
+	Duration ParamType = "duration" // e.g. '1h30m'
+	UUID     ParamType = "uuid"     // passed as string
+	Strings  ParamType = "[]string"
+	Ints     ParamType = "[]int"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// paramGoTypes maps the parameter types to the Go types passed to module methods.
+var paramGoTypes = map[ParamType]reflect.Type{
+	String:   reflect.TypeOf(""),
+	Int:      reflect.TypeOf(0),
+	Int64:    reflect.TypeOf(int64(0)),
+	Uint:     reflect.TypeOf(uint(0)),
+	Float:    reflect.TypeOf(float64(0)),
+	Bool:     reflect.TypeOf(false),
+	Time:     timeType,
+	Duration: durationType,
+	UUID:     reflect.TypeOf(""),
+	Strings:  reflect.TypeOf([]string{}),
+	Ints:     reflect.TypeOf([]int{}),
+}
+
+// goType returns the Go type of the parameter type. An empty type is a string.
+func (p ParamType) goType() (reflect.Type, error) {
+	if p == "" {
+		return paramGoTypes[String], nil
+	}
+	t, ok := paramGoTypes[p]
+	if !ok {
+		return nil, fmt.Errorf("unsupported parameter type '%s'", p)
+	}
+	return t, nil
+}
+
+// pattern returns the pattern values of the parameter type must match, if any.
+func (p ParamType) pattern() *regexp.Regexp {
+	if p == UUID {
+		return uuidPattern
+	}
+	return nil
+}
+
+// URLParam configures a single URL parameter of a request.
+//
+// Pattern restricts string parameters with a regular expression.
+// A pattern in the URI, e.g. '/users/{id:[0-9]+}', is used if Pattern is not set;
+// the router matches it for parameters of all types.
+// Validate holds validation rules, e.g. 'min=1', see AddValidator.
+type URLParam struct {
+	Name     string    `json:"name" yaml:"name"`
+	Type     ParamType `json:"type,omitempty" yaml:"type,omitempty"`
+	Pattern  string    `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Validate string    `json:"validate,omitempty" yaml:"validate,omitempty"`
+}
+
+// URLParams are the URL parameters of a request, in the order they are passed to the module method.
+//
+// In the configuration they are either a list of URLParam or a mapping of name to type, e.g.
+//
+//	params:
+//	  userID: "int"
+//	  postID: "uuid"
+//
+// Mappings keep the order of the configuration file.
+type URLParams []URLParam
+
+// UnmarshalYAML accepts a list of parameters or an ordered mapping of name to type.
+func (p *URLParams) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []URLParam
+	if e := unmarshal(&list); e == nil {
+		*p = list
+		return nil
+	}
+
+	var mapping yaml.MapSlice
+	if e := unmarshal(&mapping); e != nil {
+		return e
+	}
+	params := make(URLParams, 0, len(mapping))
+	for _, item := range mapping {
+		params = append(params, URLParam{
+			Name: fmt.Sprint(item.Key),
+			Type: ParamType(fmt.Sprint(item.Value)),
+		})
+	}
+	*p = params
+	return nil
+}
+
+// UnmarshalJSON accepts a list of parameters or an ordered object of name to type.
+func (p *URLParams) UnmarshalJSON(data []byte) error {
+	var list []URLParam
+	if e := json.Unmarshal(data, &list); e == nil {
+		*p = list
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, e := dec.Token(); e != nil || t != json.Delim('{') {
+		return fmt.Errorf("URL parameters must be a list or an object")
+	}
+	params := URLParams{}
+	for dec.More() {
+		key, e := dec.Token()
+		if e != nil {
+			return e
+		}
+		var kind string
+		if e := dec.Decode(&kind); e != nil {
+			return fmt.Errorf("type of URL parameter '%v': %v", key, e)
+		}
+		params = append(params, URLParam{Name: key.(string), Type: ParamType(kind)})
+	}
+	*p = params
+	return nil
+}
+
+// QueryParam configures a single query parameter of a request.
+//
+// In the configuration a query parameter is either just its name or an object, e.g.
+//
+//	query:
+//	  - "search"
+//	  - name: "limit"
+//	    type: "int"
+//	    default: "20"
+//	  - name: "tag"
+//	    type: "[]string"
+//	    required: true
+//	    validate: "max=5"
+//
+// The default of list types is a comma separated list.
+type QueryParam struct {
+	Name     string    `json:"name" yaml:"name"`
+	Type     ParamType `json:"type,omitempty" yaml:"type,omitempty"`
+	Default  string    `json:"default,omitempty" yaml:"default,omitempty"`
+	Required bool      `json:"required,omitempty" yaml:"required,omitempty"`
+	Validate string    `json:"validate,omitempty" yaml:"validate,omitempty"`
+}
+
+// UnmarshalYAML accepts a query parameter as its name only or as object.
+func (q *QueryParam) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if e := unmarshal(&name); e == nil {
+		*q = QueryParam{Name: name}
+		return nil
+	}
+
+	type plain QueryParam
+	return unmarshal((*plain)(q))
+}
+
+// UnmarshalJSON accepts a query parameter as its name only or as object.
+func (q *QueryParam) UnmarshalJSON(data []byte) error {
+	var name string
+	if e := json.Unmarshal(data, &name); e == nil {
+		*q = QueryParam{Name: name}
+		return nil
+	}
+
+	type plain QueryParam
+	return json.Unmarshal(data, (*plain)(q))
+}
+
+// isTyped returns true if any of the parameters declares a type.
+func isTyped(params []QueryParam) bool {
+	for _, p := range params {
+		if p.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// valueBinder converts the values of a single parameter to a Go value.
+type valueBinder struct {
+	name     string
+	typ      reflect.Type
+	pattern  *regexp.Regexp
+	required bool
+	defaults []string
+	rules    validator
+}
+
+func newValueBinder(name string, t reflect.Type, pattern *regexp.Regexp, required bool, defaultValue, rules string) (*valueBinder, error) {
+	if !isParamGoType(t) {
+		return nil, fmt.Errorf("parameter '%s' has unsupported type %s", name, t)
+	}
+
+	val, e := rulesValidator(rules, t)
+	if e != nil {
+		return nil, fmt.Errorf("parameter '%s': %v", name, e)
+	}
+
+	b := &valueBinder{name: name, typ: t, pattern: pattern, required: required, rules: val}
+	if defaultValue != "" {
+		if t.Kind() == reflect.Slice {
+			b.defaults = strings.Split(defaultValue, ",")
+		} else {
+			b.defaults = []string{defaultValue}
+		}
+		if _, e := b.parse(b.defaults); e != nil {
+			return nil, fmt.Errorf("default of parameter '%s': %v", name, e)
+		}
+	}
+	return b, nil
+}
+
+// parse checks the values against the pattern and converts them.
+func (b *valueBinder) parse(values []string) (reflect.Value, error) {
+	if b.pattern != nil {
+		for _, s := range values {
+			if !b.pattern.MatchString(s) {
+				if b.pattern == uuidPattern {
+					return reflect.Value{}, fmt.Errorf("invalid uuid '%s'", s)
+				}
+				return reflect.Value{}, fmt.Errorf("'%s' does not match pattern '%s'", s, b.pattern)
+			}
+		}
+	}
+	return parseValues(values, b.typ)
+}
+
+// bind converts the values. A missing value results in the default or zero value.
+func (b *valueBinder) bind(values []string) (reflect.Value, *FieldError) {
+	if len(values) == 0 {
+		if b.required {
+			return reflect.Value{}, &FieldError{Field: b.name, Reason: "is required"}
+		}
+		if b.defaults == nil {
+			return reflect.Zero(b.typ), nil
+		}
+		values = b.defaults
+	}
+
+	v, e := b.parse(values)
+	if e != nil {
+		return reflect.Value{}, &FieldError{Field: b.name, Reason: e.Error()}
+	}
+	return v, nil
+}
+
+// validate checks the converted value against the validation rules of the parameter.
+func (b *valueBinder) validate(v reflect.Value) []FieldError {
+	if b.rules == nil {
+		return nil
+	}
+	s := &validateState{}
+	s.pushKey(b.name)
+	b.rules(s, v)
+	return s.errors
+}
+
+// parseValues converts the values to the type t.
+// Slice types get all values, all other types the first value.
+func parseValues(values []string, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() != reflect.Slice {
+		return parseScalar(values[0], t)
+	}
+
+	v := reflect.MakeSlice(t, len(values), len(values))
+	for i, s := range values {
+		elem, e := parseScalar(s, t.Elem())
+		if e != nil {
+			return reflect.Value{}, e
+		}
+		v.Index(i).Set(elem)
+	}
+	return v, nil
+}
+
+// parseScalar converts a single string to the type t.
+func parseScalar(s string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	switch t {
+	case timeType:
+		tm, e := time.Parse(time.RFC3339, s)
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid time '%s', expected RFC 3339", s)
+		}
+		v.Set(reflect.ValueOf(tm))
+		return v, nil
+	case durationType:
+		d, e := time.ParseDuration(s)
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid duration '%s'", s)
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, e := strconv.ParseBool(s)
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid bool '%s'", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, e := strconv.ParseInt(s, 10, t.Bits())
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s '%s'", t, s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, e := strconv.ParseUint(s, 10, t.Bits())
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s '%s'", t, s)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, e := strconv.ParseFloat(s, t.Bits())
+		if e != nil {
+			return reflect.Value{}, fmt.Errorf("invalid %s '%s'", t, s)
+		}
+		v.SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+	}
+	return v, nil
+}
+
+// isParamGoType returns true if parameter values can be converted to the type t.
+func isParamGoType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t == timeType || t == durationType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// urlParamBinders returns the binders of the URL parameters of the request, in order.
+func urlParamBinders(rqst Request) ([]argBinder, error) {
+	uriPatterns := uriParams(rqst.URI)
+
+	binders := make([]argBinder, 0, len(rqst.Params))
+	for _, p := range rqst.Params {
+		uriPattern, ok := uriPatterns[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("URL parameter '%s' not found in uri '%s'", p.Name, rqst.URI)
+		}
+
+		t, e := p.Type.goType()
+		if e != nil {
+			return nil, fmt.Errorf("URL parameter '%s': %v", p.Name, e)
+		}
+		if t.Kind() == reflect.Slice {
+			return nil, fmt.Errorf("URL parameter '%s': list type '%s' not supported", p.Name, p.Type)
+		}
+
+		pattern := p.Type.pattern()
+		// The router matches the pattern of the URI, it only restricts string values again
+		expr := p.Pattern
+		if expr == "" && t.Kind() == reflect.String {
+			expr = uriPattern
+		}
+		if expr != "" {
+			if t.Kind() != reflect.String {
+				return nil, fmt.Errorf("URL parameter '%s': pattern requires a string type", p.Name)
+			}
+			if pattern, e = regexp.Compile("^(?:" + expr + ")$"); e != nil {
+				return nil, fmt.Errorf("URL parameter '%s': invalid pattern: %v", p.Name, e)
+			}
+		}
+
+		vb, e := newValueBinder(p.Name, t, pattern, true, "", p.Validate)
+		if e != nil {
+			return nil, e
+		}
+		binders = append(binders, argBinder{
+			typ: t,
+			bind: func(s *callState) (reflect.Value, error) {
+				var values []string
+				if v := chi.URLParam(s.r, vb.name); v != "" {
+					values = []string{v}
+				}
+				v, fe := vb.bind(values)
+				if fe != nil {
+					e := BadRequest("invalid URL parameters")
+					e.Fields = []FieldError{*fe}
+					return reflect.Value{}, e
+				}
+				if e := validationError(vb.validate(v)); e != nil {
+					return reflect.Value{}, e
+				}
+				return v, nil
+			},
+		})
+	}
+	return binders, nil
+}
+
+// uriParams returns the names of the URL parameters in a chi route pattern,
+// mapped to their regular expression, e.g. '/users/{id:[0-9]+}/{name}' to {id: [0-9]+, name: }.
+func uriParams(uri string) map[string]string {
+	params := make(map[string]string)
+	depth, start := 0, 0
+	for i, r := range uri {
+		switch r {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				name, expr := uri[start:i], ""
+				if j := strings.IndexByte(name, ':'); j >= 0 {
+					name, expr = name[:j], name[j+1:]
+				}
+				params[name] = expr
+			}
+		}
+	}
+	return params
+}
+
+// queryBinders returns the binders of the query parameters of the request.
+//
+// If the request sets QueryStruct, all parameters are bound into a single struct argument.
+// If no parameter declares a type, the parameters are passed as a single map[string]string.
+// Otherwise every parameter is passed as individual argument.
+func queryBinders(rqst Request) ([]argBinder, error) {
+	if rqst.QueryStruct != "" {
+		b, e := queryStructBinder(rqst.QueryStruct, rqst.Query)
+		if e != nil {
+			return nil, e
+		}
+		return []argBinder{b}, nil
+	}
+
+	valueBinders := make([]*valueBinder, len(rqst.Query))
+	for i, q := range rqst.Query {
+		t, e := q.Type.goType()
+		if e != nil {
+			return nil, fmt.Errorf("query parameter '%s': %v", q.Name, e)
+		}
+		valueBinders[i], e = newValueBinder(q.Name, t, q.Type.pattern(), q.Required, q.Default, q.Validate)
+		if e != nil {
+			return nil, fmt.Errorf("query parameter '%s': %v", q.Name, e)
+		}
+	}
+
+	if !isTyped(rqst.Query) {
+		return []argBinder{queryMapBinder(valueBinders)}, nil
+	}
+
+	binders := make([]argBinder, len(valueBinders))
+	for i, vb := range valueBinders {
+		vb := vb
+		binders[i] = argBinder{
+			typ: vb.typ,
+			bind: func(s *callState) (reflect.Value, error) {
+				v, fe := vb.bind(s.query()[vb.name])
+				if fe != nil {
+					return reflect.Value{}, queryError(*fe)
+				}
+				if e := validationError(vb.validate(v)); e != nil {
+					return reflect.Value{}, e
+				}
+				return v, nil
+			},
+		}
+	}
+	return binders, nil
+}
+
+func queryMapBinder(valueBinders []*valueBinder) argBinder {
+	return argBinder{
+		typ: stringMapType,
+		bind: func(s *callState) (reflect.Value, error) {
+			query := s.query()
+			queryParams := make(map[string]string, len(valueBinders))
+			var fields, violations []FieldError
+			for _, vb := range valueBinders {
+				v, fe := vb.bind(query[vb.name])
+				if fe != nil {
+					fields = append(fields, *fe)
+					continue
+				}
+				violations = append(violations, vb.validate(v)...)
+				queryParams[vb.name] = v.String()
+			}
+			if fields != nil {
+				return reflect.Value{}, queryError(fields...)
+			}
+			if e := validationError(violations); e != nil {
+				return reflect.Value{}, e
+			}
+			return reflect.ValueOf(queryParams), nil
+		},
+	}
+}
+
+// queryStructBinder binds the query into the fields of a registered struct.
+//
+// The parameter name of a field is taken from its 'query' tag, its 'json' tag or the field name.
+// Declared query parameters set the default, required flag and validation rules of the field with the same name,
+// in addition to the 'validate' tag of the field.
+func queryStructBinder(structName string, params []QueryParam) (argBinder, error) {
+	t := TypeRegistry[structName]
+	if t == nil {
+		return argBinder{}, fmt.Errorf("query struct type '%s' not found in type registry", structName)
+	}
+	if t.Kind() != reflect.Struct {
+		return argBinder{}, fmt.Errorf("query struct type '%s' is not a struct", structName)
+	}
+
+	declared := make(map[string]QueryParam, len(params))
+	for _, q := range params {
+		declared[q.Name] = q
+	}
+
+	fields, e := structValueBinders(t, "query", func(name string) QueryParam {
+		q := declared[name]
+		delete(declared, name)
+		return q
+	})
+	if e != nil {
+		return argBinder{}, fmt.Errorf("query struct type '%s': %v", structName, e)
+	}
+	for _, q := range params {
+		if _, ok := declared[q.Name]; ok {
+			return argBinder{}, fmt.Errorf("query parameter '%s' not found in struct type '%s'", q.Name, structName)
+		}
+	}
+
+	return argBinder{
+		typ: t,
+		bind: func(s *callState) (reflect.Value, error) {
+			v, fieldErrors, violations := bindStruct(t, fields, s.query())
+			if fieldErrors != nil {
+				return reflect.Value{}, queryError(fieldErrors...)
+			}
+			if e := validationError(violations); e != nil {
+				return reflect.Value{}, e
+			}
+			return v, nil
+		},
+	}, nil
+}
+
+// structField binds the values of a single parameter to a struct field.
+type structField struct {
+	index []int
+	*valueBinder
+}
+
+// structValueBinders returns the binders of the exported fields of the struct type t.
+// The parameter name is taken from the tag, the 'json' tag or the field name, fields tagged '-' are skipped.
+// The settings function returns the declared settings of a parameter, its rules are added to the 'validate' tag.
+func structValueBinders(t reflect.Type, tag string, settings func(name string) QueryParam) ([]structField, error) {
+	fields := []structField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name == "" {
+			name = strings.Split(f.Tag.Get("json"), ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := settings(name)
+		rules := f.Tag.Get("validate")
+		if p.Validate != "" {
+			rules += "," + p.Validate
+		}
+		vb, e := newValueBinder(name, f.Type, nil, p.Required, p.Default, rules)
+		if e != nil {
+			return nil, e
+		}
+		fields = append(fields, structField{index: f.Index, valueBinder: vb})
+	}
+	return fields, nil
+}
+
+// bindStruct creates a value of the struct type t and sets its fields from the values.
+// All field errors and violations of the validation rules are collected.
+func bindStruct(t reflect.Type, fields []structField, values url.Values) (reflect.Value, []FieldError, []FieldError) {
+	v := reflect.New(t).Elem()
+	var fieldErrors, violations []FieldError
+	for _, f := range fields {
+		fv, fe := f.bind(values[f.name])
+		if fe != nil {
+			fieldErrors = append(fieldErrors, *fe)
+			continue
+		}
+		violations = append(violations, f.validate(fv)...)
+		v.FieldByIndex(f.index).Set(fv)
+	}
+	return v, fieldErrors, violations
+}
+
+func queryError(fields ...FieldError) *Error {
+	e := BadRequest("invalid query parameters")
+	e.Fields = fields
+	return e
+}
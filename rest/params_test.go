@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type pageFilter struct {
+	Limit int `query:"limit" validate:"max=100"`
+	Page  int `query:"page" validate:"max=10"`
+}
+
+type pageModule struct{}
+
+func (pageModule) List(f pageFilter) (int, error) { return f.Limit, nil }
+
+func TestQueryStructConversionError(t *testing.T) {
+	c := NewController()
+	c.AddModule(pageModule{})
+	c.Requests = []Request{{Name: "list", Func: "List", Method: "GET", URI: "/items", QueryStruct: TypeName[pageFilter]()}}
+	h := c.Routes()
+
+	tests := []struct {
+		query  string
+		status int
+		field  string
+	}{
+		{"limit=x&page=11", http.StatusBadRequest, "limit"},
+		{"limit=5&page=11", http.StatusUnprocessableEntity, "page"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/items?"+test.query, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.query, w.Code, test.status)
+			continue
+		}
+		var problem Problem
+		if e := json.Unmarshal(w.Body.Bytes(), &problem); e != nil {
+			t.Fatal(e)
+		}
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != test.field {
+			t.Errorf("%s: errors %+v, want error of %s", test.query, problem.Errors, test.field)
+		}
+	}
+}
+
+type pageForm struct {
+	Limit int `form:"limit" validate:"max=100"`
+	Page  int `form:"page" validate:"max=10"`
+}
+
+func (pageModule) Submit(f pageForm) (int, error) { return f.Limit, nil }
+
+func TestFormStructReportsAllFieldErrors(t *testing.T) {
+	c := NewController()
+	c.AddModule(pageModule{})
+	c.Requests = []Request{{Name: "submit", Func: "Submit", Method: "POST", URI: "/notes", Body: BodyType{
+		IsMultipart: true,
+		Forms:       []MultipartForm{{StructName: TypeName[pageForm]()}},
+	}}}
+
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, multipartRequest(t, map[string][]string{"limit": {"x"}, "page": {"11"}}))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+
+	var problem Problem
+	if e := json.Unmarshal(w.Body.Bytes(), &problem); e != nil {
+		t.Fatal(e)
+	}
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "limit" || problem.Errors[1].Field != "page" {
+		t.Errorf("errors %+v, want conversion error of limit and violation of page", problem.Errors)
+	}
+}
+
+type itemModule struct{}
+
+func (itemModule) Get(id int) (int, error) { return id, nil }
+
+func TestTypedURLParamWithURIPattern(t *testing.T) {
+	c := NewController()
+	c.AddModule(itemModule{})
+	c.Requests = []Request{{
+		Name: "get", Func: "Get", Method: "GET", URI: "/x/{id:[0-9]+}",
+		Params: URLParams{{Name: "id", Type: Int}},
+	}}
+	if e := c.Validate(); e != nil {
+		t.Fatal(e)
+	}
+	h := c.Routes()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/x/42", http.StatusOK, "42\n"},
+		{"/x/abc", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: status %d, body %q", test.path, w.Code, w.Body.String())
+		}
+	}
+}
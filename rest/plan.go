@@ -0,0 +1,301 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// routePlan is the precompiled call of a module method for a Request.
+//
+// It is built once when the routes are set up, so handling a request
+// only runs the argument binders and calls the resolved method.
+type routePlan struct {
+	request Request
+	fn      reflect.Value
+	binders []argBinder
+
+	// module is the name of the module implementing fn, for logging
+	module string
+
+	// produces are the codecs the response is negotiated from
+	produces []mediaCodec
+
+	middleware []func(http.Handler) http.Handler
+}
+
+// argBinder produces a single argument of the module method from the HTTP request.
+type argBinder struct {
+	typ  reflect.Type
+	bind func(s *callState) (reflect.Value, error)
+}
+
+var (
+	contextType        = reflect.TypeOf((*context.Context)(nil)).Elem()
+	httpRequestType    = reflect.TypeOf((*http.Request)(nil))
+	responseWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	intType            = reflect.TypeOf(0)
+)
+
+// injectors bind module method parameters by their type, independent of the route configuration.
+var injectors = map[reflect.Type]argBinder{
+	contextType: {
+		typ: contextType,
+		bind: func(s *callState) (reflect.Value, error) {
+			return reflect.ValueOf(s.r.Context()), nil
+		},
+	},
+	httpRequestType: {
+		typ: httpRequestType,
+		bind: func(s *callState) (reflect.Value, error) {
+			return reflect.ValueOf(s.r), nil
+		},
+	},
+	responseWriterType: {
+		typ: responseWriterType,
+		bind: func(s *callState) (reflect.Value, error) {
+			return reflect.ValueOf(&s.w).Elem(), nil
+		},
+	},
+	requestIDType: {
+		typ: requestIDType,
+		bind: func(s *callState) (reflect.Value, error) {
+			return reflect.ValueOf(RequestIDFromContext(s.r.Context())), nil
+		},
+	},
+}
+
+// writesResponse reports whether the method declares an http.ResponseWriter parameter.
+func writesResponse(fnType reflect.Type) bool {
+	for i := 0; i < fnType.NumIn(); i++ {
+		if fnType.In(i) == responseWriterType {
+			return true
+		}
+	}
+	return false
+}
+
+// callState holds the values of a single HTTP request the binders read from.
+type callState struct {
+	w http.ResponseWriter
+	r *http.Request
+
+	queryValues url.Values
+
+	// form is the multipart form, parsed by the first form binder
+	form    *formData
+	formErr error
+}
+
+// cleanup removes the temporary files of the request.
+func (s *callState) cleanup() {
+	if s.form != nil {
+		s.form.remove()
+	}
+}
+
+// query returns the parsed query of the request.
+func (s *callState) query() url.Values {
+	if s.queryValues == nil {
+		s.queryValues = s.r.URL.Query()
+	}
+	return s.queryValues
+}
+
+// buildPlans builds the plans of the requests.
+// If any request is invalid or has the same method and URI as an earlier request,
+// a *ConfigError naming each broken route is returned.
+func (c *Controller) buildPlans(requests []Request) ([]*routePlan, error) {
+	plans := make([]*routePlan, 0, len(requests))
+	report := &ConfigError{}
+	routes := make(map[string]Request, len(requests))
+
+	for _, rqst := range requests {
+		key := routeKey(rqst)
+		p, e := c.buildPlan(rqst)
+		if first, ok := routes[key]; !ok {
+			routes[key] = rqst
+		} else if e == nil {
+			e = duplicateError(rqst, first)
+		}
+		if e != nil {
+			report.Routes = append(report.Routes, &RouteError{
+				Route:  rqst.Name,
+				Method: rqst.Method,
+				URI:    rqst.URI,
+				Err:    e,
+			})
+			continue
+		}
+		plans = append(plans, p)
+	}
+
+	if len(report.Routes) > 0 {
+		return nil, report
+	}
+	return plans, nil
+}
+
+// buildPlan resolves the module method of the request and checks that the
+// arguments built from the request match its signature.
+func (c *Controller) buildPlan(rqst Request) (*routePlan, error) {
+	fnValue, e := c.resolveMethod(rqst)
+	if e != nil {
+		return nil, e
+	}
+	fnType := fnValue.Type()
+
+	consumes, e := c.routeCodecs(rqst.Consumes)
+	if e != nil {
+		return nil, fmt.Errorf("consumes: %v", e)
+	}
+	produces, e := c.routeCodecs(rqst.Produces)
+	if e != nil {
+		return nil, fmt.Errorf("produces: %v", e)
+	}
+
+	middleware, e := c.routeMiddleware(rqst.Middleware)
+	if e != nil {
+		return nil, e
+	}
+
+	configBinders, e := c.requestBinders(rqst, consumes)
+	if e != nil {
+		return nil, e
+	}
+
+	if fnType.IsVariadic() {
+		return nil, fmt.Errorf("method '%s' must not be variadic", rqst.Func)
+	}
+
+	// Parameters of injectable types are provided by the controller,
+	// all others are taken from the route configuration in order.
+	binders := make([]argBinder, fnType.NumIn())
+	configParams := []int{}
+	for i := 0; i < fnType.NumIn(); i++ {
+		if b, ok := injectors[fnType.In(i)]; ok {
+			binders[i] = b
+			continue
+		}
+		configParams = append(configParams, i)
+	}
+
+	if len(configParams) != len(configBinders) {
+		return nil, fmt.Errorf("method '%s' expects %d arguments but the route provides %d (%s)",
+			rqst.Func, len(configParams), len(configBinders), binderTypeList(configBinders))
+	}
+	for n, i := range configParams {
+		b := configBinders[n]
+		if !b.typ.AssignableTo(fnType.In(i)) {
+			return nil, fmt.Errorf("argument %d of method '%s' is %s but the route provides %s",
+				i+1, rqst.Func, fnType.In(i), b.typ)
+		}
+		binders[i] = b
+	}
+
+	// The status code can be returned as second value.
+	// Methods writing the response with the injected http.ResponseWriter may return only an error or nothing.
+	switch {
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	case fnType.NumOut() == 3 && fnType.Out(1) == intType && fnType.Out(2) == errorType:
+	case writesResponse(fnType) && (fnType.NumOut() == 0 || fnType.NumOut() == 1 && fnType.Out(0) == errorType):
+	default:
+		return nil, fmt.Errorf("method '%s' must return (T, error) or (T, int, error), or error with an http.ResponseWriter parameter", rqst.Func)
+	}
+
+	if rqst.Status != 0 && (rqst.Status < 100 || rqst.Status > 599) {
+		return nil, fmt.Errorf("invalid status %d", rqst.Status)
+	}
+
+	return &routePlan{
+		request:  rqst,
+		fn:       fnValue,
+		module:   c.routeModule(rqst),
+		binders:  binders,
+		produces: produces,
+
+		middleware: middleware,
+	}, nil
+}
+
+// arguments runs the binders of the plan and returns the arguments for the module method.
+// Violations of validation rules are collected from all binders and returned as a single error.
+func (p *routePlan) arguments(s *callState) ([]reflect.Value, error) {
+	args := make([]reflect.Value, len(p.binders))
+	var violations []FieldError
+	for i, b := range p.binders {
+		v, e := b.bind(s)
+		if e != nil {
+			if ve, ok := isValidationError(e); ok {
+				violations = append(violations, ve.Fields...)
+				continue
+			}
+			return nil, e
+		}
+		args[i] = v
+	}
+	if e := validationError(violations); e != nil {
+		return nil, e
+	}
+	return args, nil
+}
+
+// requestBinders returns the binders for the arguments passed to the module method,
+// in the order they are passed. The body is decoded with one of the consumes codecs.
+func (c *Controller) requestBinders(rqst Request, consumes []mediaCodec) ([]argBinder, error) {
+	binders := []argBinder{}
+
+	if rqst.Headers != nil {
+		binders = append(binders, headerBinder(rqst.Headers))
+	}
+
+	if len(rqst.Params) > 0 {
+		b, e := urlParamBinders(rqst)
+		if e != nil {
+			return nil, e
+		}
+		binders = append(binders, b...)
+	}
+
+	if rqst.Query != nil || rqst.QueryStruct != "" {
+		b, e := queryBinders(rqst)
+		if e != nil {
+			return nil, e
+		}
+		binders = append(binders, b...)
+	}
+
+	if rqst.Body.IsJSON {
+		b, e := bodyBinder(rqst.Body, consumes)
+		if e != nil {
+			return nil, e
+		}
+		binders = append(binders, b)
+	}
+
+	if rqst.Body.IsMultipart {
+		parser, e := newMultipartParser(rqst.Body, c.fileScanners)
+		if e != nil {
+			return nil, e
+		}
+		for _, form := range rqst.Body.Forms {
+			b, e := multipartBinder(form, parser)
+			if e != nil {
+				return nil, e
+			}
+			binders = append(binders, b)
+		}
+	}
+
+	return binders, nil
+}
+
+func binderTypeList(binders []argBinder) string {
+	types := make([]reflect.Type, len(binders))
+	for i, b := range binders {
+		types[i] = b.typ
+	}
+	return typeList(types)
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// defaultReloadInterval is the interval configuration files are checked for changes.
+const defaultReloadInterval = 2 * time.Second
+
+// fileState identifies a version of a file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFile(path string) fileState {
+	info, e := os.Stat(path)
+	if e != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// hotReload holds the router of the current configuration.
+type hotReload struct {
+	ctx      context.Context
+	interval time.Duration
+	router   atomic.Value // *chi.Mux
+}
+
+func (h *hotReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.Load().(*chi.Mux).ServeHTTP(w, r)
+}
+
+// EnableHotReload lets Routes watch the configuration files added with AddRequestConfigFromJSON,
+// AddRequestConfigFromYAML and AddRequestConfigFromGlob, including the files they include.
+// Must be called before Routes. Files of a fs.FS are read again but not watched,
+// new files matching an include pattern are read with the next change of a watched file.
+//
+// The files are checked for changes every interval, 2 seconds if interval is 0.
+// On a change all files are read again and the new configuration is checked against the modules.
+// If it is valid, the routes are replaced at once; otherwise the previous routes are kept and the error is logged.
+// Requests that were not loaded from files are kept. Watching stops when ctx is done.
+func (c *Controller) EnableHotReload(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	c.hotReload = &hotReload{ctx: ctx, interval: interval}
+}
+
+// startHotReload serves the routes of the plans through the replaceable router and starts watching the files.
+func (c *Controller) startHotReload(plans []*routePlan, requests []Request) {
+	c.hotReload.router.Store(c.newRouter(plans, requests))
+	c.Mux.Handle("/*", c.hotReload)
+
+	states := c.configStates()
+	go func() {
+		ticker := time.NewTicker(c.hotReload.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.hotReload.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := c.configStates()
+			if statesEqual(states, current) {
+				continue
+			}
+			states = current
+
+			if e := c.reloadConfig(); e != nil {
+				c.logger.Error("could not reload route configuration, keeping the previous routes", "error", e)
+				continue
+			}
+			c.logger.Info("reloaded route configuration")
+		}
+	}()
+}
+
+// reloadConfig reads the configuration files again and replaces the router if the configuration is valid.
+func (c *Controller) reloadConfig() error {
+	requests := []Request{}
+	for _, rqst := range c.currentRequests() {
+		if rqst.source == "" {
+			requests = append(requests, rqst)
+		}
+	}
+	watched := []string{}
+	for _, f := range c.configFiles {
+		fileRequests, files, e := f.load()
+		if e != nil {
+			return e
+		}
+		requests = append(requests, fileRequests...)
+		if f.fsys == nil {
+			watched = append(watched, files...)
+		}
+	}
+
+	plans, e := c.buildPlans(requests)
+	if e != nil {
+		return e
+	}
+
+	c.hotReload.router.Store(c.newRouter(plans, requests))
+	c.mu.Lock()
+	c.Requests = requests
+	c.watchedFiles = watched
+	c.mu.Unlock()
+	return nil
+}
+
+// currentRequests returns the requests of the current configuration, which hot reloads replace.
+func (c *Controller) currentRequests() []Request {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Requests
+}
+
+// newRouter returns a router with the routes of the plans.
+func (c *Controller) newRouter(plans []*routePlan, requests []Request) *chi.Mux {
+	router := chi.NewMux()
+	c.mountRoutes(router, plans, requests)
+	return router
+}
+
+func (c *Controller) configStates() []fileState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make([]fileState, len(c.watchedFiles))
+	for i, path := range c.watchedFiles {
+		states[i] = statFile(path)
+	}
+	return states
+}
+
+func statesEqual(a, b []fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].exists != b[i].exists || a[i].size != b[i].size || !a[i].modTime.Equal(b[i].modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type reloadModule struct{}
+
+func (reloadModule) A() (string, error) { return "a", nil }
+func (reloadModule) B() (string, error) { return "b", nil }
+
+// TestHotReloadConcurrentReads reads the configuration while it is reloaded, run with -race.
+func TestHotReloadConcurrentReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if e := os.WriteFile(path, []byte("- {name: a, func: A, method: GET, uri: /a}\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	c := NewController()
+	c.AddModule(reloadModule{})
+	if e := c.AddRequestConfigFromYAML(path); e != nil {
+		t.Fatal(e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.EnableHotReload(ctx, 5*time.Millisecond)
+	c.Routes()
+
+	routes := "- {name: a, func: A, method: GET, uri: /a}\n- {name: b, func: B, method: GET, uri: /b}\n"
+	if e := os.WriteFile(path, []byte(routes), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if e := c.Validate(); e != nil {
+			t.Fatal(e)
+		}
+		doc, e := c.OpenAPI()
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, ok := doc.Paths["/b"]; ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("configuration was not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,26 +1,66 @@
 package rest
 
+import "reflect"
+
 type Request struct {
-	Name    string            `json:"name" yaml:"name"`
-	Func    string            `json:"func" yaml:"func"`
-	Method  string            `json:"method" yaml:"method"`
-	URI     string            `json:"uri" yaml:"uri"`
-	Headers []string          `json:"headers,omitempty" yaml:"headers,omitempty"`
-	Body    BodyType          `json:"body,omitempty" yaml:"body,omitempty"`
-	Params  map[string]string `json:"params,omitempty" yaml:"params,omitempty"` // URL Params
-	Query   []string          `json:"query,omitempty" yaml:"query,omitempty"`   // Query params
+	Name    string       `json:"name" yaml:"name"`
+	Func    string       `json:"func" yaml:"func"`
+	Module  string       `json:"module,omitempty" yaml:"module,omitempty"` // Name of the module implementing Func
+	Method  string       `json:"method" yaml:"method"`
+	URI     string       `json:"uri" yaml:"uri"`
+	Headers []string     `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    BodyType     `json:"body,omitempty" yaml:"body,omitempty"`
+	Params  URLParams    `json:"params,omitempty" yaml:"params,omitempty"` // URL Params
+	Query   []QueryParam `json:"query,omitempty" yaml:"query,omitempty"`   // Query params
+
+	// QueryStruct is the TypeRegistry name of a struct the query parameters are bound into.
+	QueryStruct string `json:"queryStruct,omitempty" yaml:"queryStruct,omitempty"`
+
+	// Consumes and Produces restrict the media types of the request body and the response,
+	// in order of preference. JSON and the media types added with Controller.AddCodec are allowed if empty;
+	// XML, YAML and CSV must be listed to be used.
+	Consumes []string `json:"consumes,omitempty" yaml:"consumes,omitempty"`
+	Produces []string `json:"produces,omitempty" yaml:"produces,omitempty"`
+
+	// Status is the status code of successful responses, 200 if not set.
+	// Module methods can override it, see Response.
+	Status int `json:"status,omitempty" yaml:"status,omitempty"`
+
+	// Middleware are the names of middleware wrapping the handler of the route, see Controller.AddMiddleware.
+	Middleware []string `json:"middleware,omitempty" yaml:"middleware,omitempty"`
+
+	// source is the configuration file the request was loaded from
+	source string
+	// handler is the function of a route declared with a RouteBuilder
+	handler reflect.Value
 }
 
 type BodyType struct {
 	IsJSON         bool   `yaml:"isJSON,omitempty"`
 	JSONStructName string `yaml:"jsonStructName,omitempty"`
+	// Validate maps paths of JSON field names, e.g. 'sender.name', to validation rules
+	Validate map[string]string `yaml:"validate,omitempty"`
 
 	IsMultipart bool            `yaml:"isMultipart,omitempty"`
 	Forms       []MultipartForm `yaml:"forms,omitempty"`
+	// Limits of multipart bodies, not limited if 0.
+	// Without MaxTotalSize, text values are limited to 32MB in total.
+	MaxFileSize  ByteSize `yaml:"maxFileSize,omitempty"`
+	MaxTotalSize ByteSize `yaml:"maxTotalSize,omitempty"`
+	MaxFiles     int      `yaml:"maxFiles,omitempty"`
 }
 
 type MultipartForm struct {
 	Name       string `yaml:"name,omitempty"`
 	IsFile     bool   `yaml:"isFile,omitempty"`
 	StructName string `yaml:"structName,omitempty"`
+	// Multiple passes all files or values of the field, as []*FileInfo or []string
+	Multiple bool `yaml:"multiple,omitempty"`
+
+	// Upload rules of files. AllowedTypes are checked against the type detected from the content
+	// and may contain wildcards like 'image/*'. OnMismatch is either 'ignore' (default) or 'reject'
+	// for files whose extension does not match the detected type.
+	AllowedTypes      []string `yaml:"allowedTypes,omitempty"`
+	AllowedExtensions []string `yaml:"allowedExtensions,omitempty"`
+	OnMismatch        string   `yaml:"onMismatch,omitempty"`
 }
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// DefaultRequestIDHeader is the header the request ID is read from and written to.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits the length of request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID identifies a request in logs, error responses and downstream calls.
+// Module methods get the ID of the request by declaring a parameter of type RequestID.
+type RequestID string
+
+var requestIDType = reflect.TypeOf(RequestID(""))
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request handled with ctx, or "" outside of requests.
+func RequestIDFromContext(ctx context.Context) RequestID {
+	id, _ := ctx.Value(requestIDKey{}).(RequestID)
+	return id
+}
+
+// SetRequestIDHeader changes the header the request ID is read from and written to, X-Request-ID by default.
+func (c *Controller) SetRequestIDHeader(name string) {
+	c.requestIDHeader = name
+}
+
+// withRequestID adds the ID of the request to its context and to the response headers.
+//
+// The ID is taken from the request header, from chi's RequestID middleware, or generated as random UUID.
+// IDs from clients are only accepted if they are printable ASCII of at most 128 characters.
+func (c *Controller) withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	header := c.requestIDHeader
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	id := r.Header.Get(header)
+	if !validRequestID(id) {
+		id = middleware.GetReqID(r.Context())
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+
+	w.Header().Set(header, id)
+	ctx := context.WithValue(r.Context(), requestIDKey{}, RequestID(id))
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, id)
+	return r.WithContext(ctx)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random UUID (version 4).
+func newRequestID() string {
+	var b [16]byte
+	if _, e := rand.Read(b[:]); e != nil {
+		panic(fmt.Sprintf("could not generate request id: %v", e))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+type requestIDModule struct{}
+
+func (requestIDModule) Get(id RequestID) (string, error) { return string(id), nil }
+func (requestIDModule) Fail(id RequestID) (string, error) {
+	return "", NotFound("request %s failed", id)
+}
+
+var uuidV4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func requestIDController() *Controller {
+	c := NewController()
+	c.AddModule(requestIDModule{})
+	c.Requests = []Request{
+		{Name: "get", Func: "Get", Method: "GET", URI: "/id"},
+		{Name: "fail", Func: "Fail", Method: "GET", URI: "/fail"},
+	}
+	return c
+}
+
+func TestRequestID(t *testing.T) {
+	h := requestIDController().Routes()
+
+	tests := []struct {
+		name     string
+		incoming string
+		accepted bool
+	}{
+		{"valid", "req-42/a:b", true},
+		{"max length", strings.Repeat("a", 128), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"space", "req 42", false},
+		{"control character", "req\x0142", false},
+		{"non-ASCII", "req-ä", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/id", nil)
+		if test.incoming != "" {
+			r.Header.Set("X-Request-ID", test.incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		var injected string
+		if e := json.Unmarshal(w.Body.Bytes(), &injected); e != nil {
+			t.Fatalf("%s: %v", test.name, e)
+		}
+		header := w.Header().Get("X-Request-ID")
+		if injected != header {
+			t.Errorf("%s: injected id %q, response header %q", test.name, injected, header)
+		}
+		if test.accepted && header != test.incoming {
+			t.Errorf("%s: id %q, want incoming id", test.name, header)
+		}
+		if !test.accepted && !uuidV4Pattern.MatchString(header) {
+			t.Errorf("%s: id %q, want generated UUID", test.name, header)
+		}
+	}
+}
+
+func TestRequestIDUnique(t *testing.T) {
+	h := requestIDController().Routes()
+	ids := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/id", nil))
+		ids[w.Header().Get("X-Request-ID")] = true
+	}
+	if len(ids) != 100 {
+		t.Errorf("%d different ids for 100 requests", len(ids))
+	}
+}
+
+func TestRequestIDInProblem(t *testing.T) {
+	h := requestIDController().Routes()
+	r := httptest.NewRequest("GET", "/fail", nil)
+	r.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound || w.Header().Get("X-Request-ID") != "req-42" {
+		t.Fatalf("status %d, id %q", w.Code, w.Header().Get("X-Request-ID"))
+	}
+	var problem Problem
+	if e := json.Unmarshal(w.Body.Bytes(), &problem); e != nil {
+		t.Fatal(e)
+	}
+	if problem.RequestID != "req-42" || problem.Detail != "request req-42 failed" {
+		t.Errorf("problem %+v", problem)
+	}
+}
+
+func TestRequestIDHeaderAndChiMiddleware(t *testing.T) {
+	c := requestIDController()
+	c.SetRequestIDHeader("X-Correlation-ID")
+
+	r := httptest.NewRequest("GET", "/id", nil)
+	r.Header.Set("X-Correlation-ID", "corr-1")
+	r.Header.Set("X-Request-ID", "ignored")
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, r)
+	if w.Header().Get("X-Correlation-ID") != "corr-1" || w.Body.String() != "\"corr-1\"\n" {
+		t.Errorf("id %q, body %s", w.Header().Get("X-Correlation-ID"), w.Body.String())
+	}
+
+	// Without a valid header the ID of chi's RequestID middleware is used
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Mount("/", requestIDController().Routes())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/id", nil))
+	id := w.Header().Get("X-Request-ID")
+	if id == "" || uuidV4Pattern.MatchString(id) || w.Body.String() != "\""+id+"\"\n" {
+		t.Errorf("id %q, body %s, want id of the chi middleware", id, w.Body.String())
+	}
+}
@@ -2,11 +2,144 @@ package rest
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
 )
 
+// Response can be returned by module methods to set the status code, headers and cookies of the response.
+// Body is encoded like any other result, no body is written if it is nil.
+type Response struct {
+	Status  int
+	Header  http.Header
+	Cookies []*http.Cookie
+	Body    interface{}
+}
+
+// StatusCoder can be implemented by results of module methods to choose the status code of the response.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Created returns a 201 response with the Location header.
+func Created(location string, body interface{}) *Response {
+	return &Response{
+		Status: http.StatusCreated,
+		Header: http.Header{"Location": {location}},
+		Body:   body,
+	}
+}
+
+// NoContent returns a 204 response without body.
+func NoContent() *Response {
+	return &Response{Status: http.StatusNoContent}
+}
+
+var responseType = reflect.TypeOf(Response{})
+
+func isResponseType(t reflect.Type) bool {
+	return t == responseType || t == reflect.PtrTo(responseType)
+}
+
+// writeResponse writes the result of a module method.
+//
+// The status code is, in order of precedence, the status of a Response, the status returned by the method,
+// the status of a StatusCoder result, the status of the route or 200.
+func (c *Controller) writeResponse(w http.ResponseWriter, r *http.Request, plan *routePlan, mc mediaCodec, status int, result interface{}) {
+	body, hasBody := result, true
+	switch resp := result.(type) {
+	case Response:
+		status, body, hasBody = applyResponse(w, &resp, status)
+	case *Response:
+		if resp == nil {
+			hasBody = false
+			break
+		}
+		status, body, hasBody = applyResponse(w, resp, status)
+	}
+
+	if status == 0 {
+		if sc, ok := body.(StatusCoder); ok && !isNilPointer(body) {
+			status = sc.StatusCode()
+		}
+	}
+	if status == 0 {
+		status = plan.request.Status
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// Informational, 204 and 304 responses must not have a body
+	if !hasBody || status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+
+	if c.rw != nil {
+		sw := &statusWriter{ResponseWriter: w, status: status}
+		if e := c.rw.Write(sw, body); e != nil {
+			if !sw.wroteHeader {
+				c.internalError(w, r, fmt.Errorf("could not write response: %v", e))
+				return
+			}
+			c.logRequestAt(r, slog.LevelError, "could not write response", "error", e)
+		}
+		return
+	}
+	c.writeResult(w, r, mc, status, body)
+}
+
+// applyResponse sets the headers and cookies of the response and returns its status and body.
+func applyResponse(w http.ResponseWriter, resp *Response, status int) (int, interface{}, bool) {
+	for name, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	for _, cookie := range resp.Cookies {
+		http.SetCookie(w, cookie)
+	}
+	if resp.Status != 0 {
+		status = resp.Status
+	}
+	return status, resp.Body, resp.Body != nil
+}
+
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// statusWriter writes the status code when the IResponseWriter writes the body,
+// so that it can still set headers. A status code set by the IResponseWriter is kept.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(w.status)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// IResponseWriter writes the results of module methods.
 type IResponseWriter interface {
 	Write(w http.ResponseWriter, content interface{}) error
+
+	// Deprecated: errors are written as application/problem+json by the Controller,
+	// see Controller.SetErrorMapper.
 	WriteError(w http.ResponseWriter, content interface{}) error
 }
 
@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type acceptedJob struct {
+	ID int `json:"id"`
+}
+
+func (acceptedJob) StatusCode() int { return http.StatusAccepted }
+
+type responseModule struct{}
+
+func (responseModule) Full() (*Response, error) {
+	return &Response{
+		Status:  http.StatusPartialContent,
+		Header:  http.Header{"X-Total": {"3"}},
+		Cookies: []*http.Cookie{{Name: "session", Value: "abc"}},
+		Body:    "part",
+	}, nil
+}
+func (responseModule) Value() (Response, error)         { return Response{Body: "value"}, nil }
+func (responseModule) Create() (*Response, error)       { return Created("/jobs/1", acceptedJob{ID: 1}), nil }
+func (responseModule) Delete() (*Response, error)       { return NoContent(), nil }
+func (responseModule) Job() (acceptedJob, error)        { return acceptedJob{ID: 2}, nil }
+func (responseModule) Teapot() (string, int, error)     { return "tea", http.StatusTeapot, nil }
+func (responseModule) Plain() (string, error)           { return "plain", nil }
+func (responseModule) Empty() (string, int, error)      { return "ignored", http.StatusNoContent, nil }
+func (responseModule) Missing() (*Response, error)      { return nil, nil }
+func (responseModule) JobStatus() (*acceptedJob, error) { return nil, nil }
+
+func TestWriteResponse(t *testing.T) {
+	c := NewController()
+	c.AddModule(responseModule{})
+	c.Requests = []Request{
+		{Name: "full", Func: "Full", Method: "GET", URI: "/full"},
+		{Name: "value", Func: "Value", Method: "GET", URI: "/value", Status: http.StatusAccepted},
+		{Name: "create", Func: "Create", Method: "POST", URI: "/jobs"},
+		{Name: "delete", Func: "Delete", Method: "DELETE", URI: "/jobs"},
+		{Name: "job", Func: "Job", Method: "GET", URI: "/job", Status: http.StatusCreated},
+		{Name: "teapot", Func: "Teapot", Method: "GET", URI: "/teapot", Status: http.StatusCreated},
+		{Name: "plain", Func: "Plain", Method: "GET", URI: "/plain", Status: http.StatusCreated},
+		{Name: "default", Func: "Plain", Method: "GET", URI: "/default"},
+		{Name: "empty", Func: "Empty", Method: "GET", URI: "/empty"},
+		{Name: "missing", Func: "Missing", Method: "GET", URI: "/missing"},
+		{Name: "nil status coder", Func: "JobStatus", Method: "GET", URI: "/nil"},
+	}
+	h := c.Routes()
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		header map[string]string
+	}{
+		{"GET", "/full", http.StatusPartialContent, "\"part\"\n", map[string]string{"X-Total": "3", "Set-Cookie": "session=abc"}},
+		{"GET", "/value", http.StatusAccepted, "\"value\"\n", nil},
+		{"POST", "/jobs", http.StatusCreated, "{\"id\":1}\n", map[string]string{"Location": "/jobs/1"}},
+		{"DELETE", "/jobs", http.StatusNoContent, "", nil},
+		{"GET", "/job", http.StatusAccepted, "{\"id\":2}\n", nil},
+		{"GET", "/teapot", http.StatusTeapot, "\"tea\"\n", nil},
+		{"GET", "/plain", http.StatusCreated, "\"plain\"\n", nil},
+		{"GET", "/default", http.StatusOK, "\"plain\"\n", nil},
+		{"GET", "/empty", http.StatusNoContent, "", nil},
+		{"GET", "/missing", http.StatusOK, "", nil},
+		{"GET", "/nil", http.StatusOK, "null\n", nil},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%s %s: status %d, body %q, want %d, %q", test.method, test.path, w.Code, w.Body.String(), test.status, test.body)
+		}
+		for name, want := range test.header {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("%s %s: header %s %q, want %q", test.method, test.path, name, got, want)
+			}
+		}
+	}
+}
+
+// failingWriter fails before or after writing the response.
+type failingWriter struct {
+	afterWrite bool
+}
+
+func (fw failingWriter) Write(w http.ResponseWriter, content interface{}) error {
+	if fw.afterWrite {
+		w.Write([]byte("partial"))
+	}
+	return errors.New("connection lost")
+}
+
+func (fw failingWriter) WriteError(w http.ResponseWriter, content interface{}) error {
+	return fw.Write(w, content)
+}
+
+func TestWriteResponseWriterError(t *testing.T) {
+	tests := []struct {
+		afterWrite bool
+		status     int
+	}{
+		{false, http.StatusInternalServerError},
+		{true, http.StatusOK},
+	}
+	for _, test := range tests {
+		var logs bytes.Buffer
+		c := NewController()
+		c.SetLogHandler(slog.NewTextHandler(&logs, nil))
+		c.SetWriter(failingWriter{afterWrite: test.afterWrite})
+		c.AddModule(responseModule{})
+		c.Requests = []Request{{Name: "plain", Func: "Plain", Method: "GET", URI: "/plain"}}
+
+		w := httptest.NewRecorder()
+		c.Routes().ServeHTTP(w, httptest.NewRequest("GET", "/plain", nil))
+		if w.Code != test.status {
+			t.Errorf("after write %v: status %d, want %d", test.afterWrite, w.Code, test.status)
+		}
+		if !strings.Contains(logs.String(), "connection lost") {
+			t.Errorf("after write %v: error not logged: %s", test.afterWrite, logs.String())
+		}
+	}
+}
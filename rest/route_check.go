@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	stringMapType = reflect.TypeOf(map[string]string{})
+)
+
+// RouteError describes a route whose configuration does not match its module method.
+type RouteError struct {
+	Route  string
+	Method string
+	URI    string
+	Err    error
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("route '%s' (%s %s): %v", e.Route, e.Method, e.URI, e.Err)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError is returned by Validate and lists every broken route.
+// Loading a route configuration returns it for routes with unsupported parameter types.
+type ConfigError struct {
+	Routes []*RouteError
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, 0, len(e.Routes)+1)
+	lines = append(lines, fmt.Sprintf("invalid route configuration (%d broken routes):", len(e.Routes)))
+	for _, re := range e.Routes {
+		lines = append(lines, "\t"+re.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks every configured request against the methods of the registered modules.
+//
+// For each request the module method must exist, accept the arguments built from
+// headers, URL parameters, query parameters and body in count and order,
+// and return the results described at HandleRequest.
+//
+// If any request is invalid, a *ConfigError naming each broken route is returned.
+func (c *Controller) Validate() error {
+	_, e := c.buildPlans(c.currentRequests())
+	return e
+}
+
+func typeList(types []reflect.Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
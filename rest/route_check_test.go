@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type checkModule struct{}
+
+func (checkModule) Get(id int) (string, error)    { return "", nil }
+func (checkModule) NoError(id int) (string, bool) { return "", true }
+
+func TestValidateReportsAllRoutes(t *testing.T) {
+	c := NewController()
+	c.AddModule(checkModule{})
+	params := URLParams{{Name: "id", Type: Int}}
+	c.Requests = []Request{
+		{Name: "ok", Func: "Get", Method: "GET", URI: "/ok/{id}", Params: params},
+		{Name: "missing", Func: "Missing", Method: "GET", URI: "/missing"},
+		{Name: "arguments", Func: "Get", Method: "GET", URI: "/arguments"},
+		{Name: "argument type", Func: "Get", Method: "GET", URI: "/type/{id}", Params: URLParams{{Name: "id", Type: Bool}}},
+		{Name: "results", Func: "NoError", Method: "GET", URI: "/results/{id}", Params: params},
+		{Name: "middleware", Func: "Get", Method: "GET", URI: "/middleware/{id}", Params: params, Middleware: []string{"auth"}},
+		{Name: "status", Func: "Get", Method: "GET", URI: "/status/{id}", Params: params, Status: 99},
+		{Name: "duplicate", Func: "Get", Method: "GET", URI: "/ok/{key}", Params: URLParams{{Name: "key", Type: Int}}},
+	}
+
+	e := c.Validate()
+	var ce *ConfigError
+	if !errors.As(e, &ce) {
+		t.Fatalf("error %v, want *ConfigError", e)
+	}
+	want := []string{
+		"method 'Missing' not found",
+		"method 'Get' expects 1 arguments but the route provides 0",
+		"argument 1 of method 'Get' is int but the route provides bool",
+		"method 'NoError' must return",
+		"middleware 'auth' not found",
+		"invalid status 99",
+		"duplicate of route 'ok'",
+	}
+	if len(ce.Routes) != len(want) {
+		t.Fatalf("%d broken routes, want %d:\n%v", len(ce.Routes), len(want), e)
+	}
+	for i, re := range ce.Routes {
+		if re.Route != c.Requests[i+1].Name || re.URI != c.Requests[i+1].URI || !strings.Contains(re.Err.Error(), want[i]) {
+			t.Errorf("route error %v, want route '%s' with %s", re, c.Requests[i+1].Name, want[i])
+		}
+	}
+	if !strings.HasPrefix(e.Error(), "invalid route configuration (7 broken routes):\n\troute 'missing' (GET /missing): ") {
+		t.Errorf("error %q", e.Error())
+	}
+
+	// Routes panics with the same error
+	defer func() {
+		if p, ok := recover().(*ConfigError); !ok || len(p.Routes) != len(want) {
+			t.Errorf("panic %v, want *ConfigError", p)
+		}
+	}()
+	c.Routes()
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the spans of the Controller.
+const tracerName = "github.com/benschs/go-api/rest"
+
+// TracingSettings configures the OpenTelemetry tracing of the routes.
+//
+// Every request handled by a route gets a server span named after the route, with child spans
+// 'bind arguments', 'call module' and 'write response'. The trace context of the request headers
+// is the parent of the server span, and module methods get the context of the 'call module' span.
+type TracingSettings struct {
+	// TracerProvider creates the tracer, the global provider of otel.GetTracerProvider if nil.
+	TracerProvider trace.TracerProvider
+
+	// Propagator extracts the trace context from the request headers,
+	// the W3C trace context (traceparent and tracestate headers) if nil.
+	Propagator propagation.TextMapPropagator
+}
+
+// SetTracing enables OpenTelemetry tracing of the routes. Must be called before Routes().
+func (c *Controller) SetTracing(s TracingSettings) {
+	if s.TracerProvider == nil {
+		s.TracerProvider = otel.GetTracerProvider()
+	}
+	if s.Propagator == nil {
+		s.Propagator = propagation.TraceContext{}
+	}
+	c.tracer = s.TracerProvider.Tracer(tracerName)
+	c.propagator = s.Propagator
+}
+
+// nonRecordingSpan is used if tracing is disabled.
+var nonRecordingSpan = trace.SpanFromContext(context.Background())
+
+// traceRequest starts the server span of the route, as child of the trace context of the request headers.
+func (c *Controller) traceRequest(r *http.Request, plan *routePlan) (*http.Request, trace.Span) {
+	if c.tracer == nil {
+		return r, nonRecordingSpan
+	}
+
+	ctx := c.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := c.tracer.Start(ctx, plan.request.Name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", plan.request.URI),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("code.function", plan.request.Func),
+			attribute.String("code.namespace", plan.module),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// endTrace sets the status code of the response on the server span and ends it.
+// Responses with status 500 and above mark the span as failed.
+func endTrace(r *http.Request, span trace.Span) {
+	if !span.IsRecording() {
+		return
+	}
+	if l, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok && l.w.status != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", l.w.status))
+		if l.w.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(l.w.status))
+		}
+	}
+	span.End()
+}
+
+// startSpan starts a child span of the span in ctx.
+func (c *Controller) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, nonRecordingSpan
+	}
+	return c.tracer.Start(ctx, name)
+}
+
+// recordError records the error on the server span of the request.
+func (c *Controller) recordError(r *http.Request, e error) {
+	if c.tracer != nil {
+		trace.SpanFromContext(r.Context()).RecordError(e)
+	}
+}
+
+// withCallContext replaces context arguments with ctx, so that spans of the module are children of the call span.
+func withCallContext(ctx context.Context, plan *routePlan, args []reflect.Value) {
+	for i, b := range plan.binders {
+		if b.typ == contextType {
+			args[i] = reflect.ValueOf(ctx)
+		}
+	}
+}
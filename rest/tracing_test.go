@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedModule struct {
+	spans chan trace.SpanContext
+}
+
+func (m tracedModule) Get(ctx context.Context, id int) (int, error) {
+	m.spans <- trace.SpanContextFromContext(ctx)
+	return id, nil
+}
+
+func TestTracingSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	m := tracedModule{spans: make(chan trace.SpanContext, 1)}
+	c := NewController()
+	c.SetTracing(TracingSettings{TracerProvider: tp})
+	c.AddModule(m)
+	c.Requests = []Request{{
+		Name: "get item", Func: "Get", Method: "GET", URI: "/items/{id}",
+		Params: URLParams{{Name: "id", Type: "int"}},
+	}}
+
+	r := httptest.NewRequest("GET", "/items/3", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	c.Routes().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	moduleSpan := <-m.spans
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+
+	server, ok := spans["get item"]
+	if !ok {
+		t.Fatalf("no server span, got %v", spans)
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span kind %v", server.SpanKind)
+	}
+	if got := server.Parent.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace %s, want trace of traceparent", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" || !server.Parent.IsRemote() {
+		t.Errorf("server span parent %s, want remote parent of traceparent", got)
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range server.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	want := map[attribute.Key]string{
+		"http.request.method":       "GET",
+		"http.route":                "/items/{id}",
+		"url.path":                  "/items/3",
+		"code.function":             "Get",
+		"http.response.status_code": "200",
+	}
+	for key, value := range want {
+		if got := attrs[key].Emit(); got != value {
+			t.Errorf("attribute %s = %q, want %q", key, got, value)
+		}
+	}
+
+	for _, name := range []string{"bind arguments", "call module", "write response"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("no span '%s'", name)
+			continue
+		}
+		if s.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("span '%s' is not a child of the server span", name)
+		}
+	}
+	if call := spans["call module"]; moduleSpan.SpanID() != call.SpanContext.SpanID() {
+		t.Errorf("module context has span %s, want 'call module' span %s", moduleSpan.SpanID(), call.SpanContext.SpanID())
+	}
+}
@@ -1,8 +1,18 @@
 package rest
 
 import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // ParseType converts the src interface to the type t.
@@ -14,6 +24,9 @@ import (
 //	myStructValue := v.(MyStruct)
 //
 // This is meant to be used to convert json to any type.
+// The conversion follows the rules of encoding/json.Unmarshal, src is expected to be
+// unmarshalled JSON with numbers as float64 or, preferably, as json.Number.
+// Numbers are never converted lossy, e.g. 1.9 can not be parsed to an int.
 func ParseType(src interface{}, t reflect.Type) (interface{}, error) {
 	v, e := parseTypeToValue(src, t)
 	if e != nil {
@@ -22,125 +35,849 @@ func ParseType(src interface{}, t reflect.Type) (interface{}, error) {
 	return v.Interface(), nil
 }
 
-func parseTypeToValue(src interface{}, t reflect.Type) (*reflect.Value, error) {
+// parseTypeToValue converts the src interface to a new value of type t.
+// If any value can not be converted, a *DecodeError listing all of them is returned.
+func parseTypeToValue(src interface{}, t reflect.Type) (reflect.Value, error) {
+	return decodeValue(decoderFor(t), src, t)
+}
+
+// decodeValue runs the decoder on a new value of type t.
+func decodeValue(dec decoder, src interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	d := &decodeState{}
+	dec(d, src, v)
+	if d.errors != nil {
+		return reflect.Value{}, &DecodeError{Errors: d.errors}
+	}
+	return v, nil
+}
+
+// DecodeError lists every value that could not be converted by the type parser.
+// The field of each error is the path of the value, e.g. 'sender.id' or 'items[3].price'.
+// The field is empty for the root value.
+type DecodeError struct {
+	Errors []FieldError
+}
+
+func (e *DecodeError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		if fe.Field == "" {
+			msgs[i] = fe.Reason
+		} else {
+			msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Reason)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// HTTPStatus returns 400, as decode errors are caused by the request.
+func (e *DecodeError) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	numberType          = reflect.TypeOf(json.Number(""))
+)
+
+// decodeState holds the current path and the errors of a single conversion.
+type decodeState struct {
+	path   []pathElem
+	errors []FieldError
+}
+
+// pathElem is an object key or, if index is not negative, an array index.
+type pathElem struct {
+	key   string
+	index int
+}
+
+func (d *decodeState) pushKey(key string) {
+	d.path = append(d.path, pathElem{key: key, index: -1})
+}
+
+func (d *decodeState) pushIndex(i int) {
+	d.path = append(d.path, pathElem{index: i})
+}
+
+func (d *decodeState) pop() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+func (d *decodeState) pathString() string {
+	var b strings.Builder
+	for _, p := range d.path {
+		if p.index >= 0 {
+			b.WriteString("[" + strconv.Itoa(p.index) + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(p.key)
+	}
+	return b.String()
+}
+
+// saveError records an error returned by an unmarshaler or the decoding of a value.
+func (d *decodeState) saveError(src interface{}, t reflect.Type, e error) {
+	d.errors = append(d.errors, FieldError{
+		Field:    d.pathString(),
+		Reason:   e.Error(),
+		Expected: t.String(),
+		Received: jsonKind(src),
+	})
+}
+
+func (d *decodeState) typeError(src interface{}, t reflect.Type) {
+	d.errors = append(d.errors, FieldError{
+		Field:    d.pathString(),
+		Reason:   fmt.Sprintf("cannot unmarshal %s into value of type %s", jsonKind(src), t),
+		Expected: t.String(),
+		Received: jsonKind(src),
+	})
+}
+
+// numberError reports a number that can not be converted to the type t without loss.
+func (d *decodeState) numberError(literal string, t reflect.Type) {
+	d.errors = append(d.errors, FieldError{
+		Field:    d.pathString(),
+		Reason:   fmt.Sprintf("cannot unmarshal number %s into value of type %s", literal, t),
+		Expected: t.String(),
+		Received: "number",
+	})
+}
+
+// jsonKind returns the JSON type of the unmarshalled value, as used in error messages.
+func jsonKind(src interface{}) string {
+	switch src.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", src)
+	}
+}
+
+// decoder sets the value v from the unmarshalled JSON value src.
+// v is always addressable.
+type decoder func(d *decodeState, src interface{}, v reflect.Value)
+
+// decoderCache holds the compiled decoder of every type parsed so far.
+var decoderCache sync.Map // map[reflect.Type]decoder
+
+// decoderFor returns the decoder for the type t, compiling it on first use.
+func decoderFor(t reflect.Type) decoder {
+	if d, ok := decoderCache.Load(t); ok {
+		return d.(decoder)
+	}
+	d, _ := decoderCache.LoadOrStore(t, compileDecoder(t, make(map[reflect.Type]*decoder)))
+	return d.(decoder)
+}
+
+// compileDecoder builds the decoder for the type t.
+// Types currently being compiled are kept in building to support recursive types.
+func compileDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	if d, ok := decoderCache.Load(t); ok {
+		return d.(decoder)
+	}
+	if d, ok := building[t]; ok {
+		return func(ds *decodeState, src interface{}, v reflect.Value) {
+			(*d)(ds, src, v)
+		}
+	}
+
+	d := new(decoder)
+	building[t] = d
+
+	// Unmarshalers take precedence over the kind of the type
+	pt := reflect.PtrTo(t)
+	switch {
+	case t.Kind() != reflect.Ptr && pt.Implements(jsonUnmarshalerType):
+		*d = unmarshalerDecoder
+		return *d
+	case t.Kind() != reflect.Ptr && pt.Implements(textUnmarshalerType):
+		*d = textUnmarshalerDecoder(t)
+		return *d
+	}
 
 	switch t.Kind() {
+	case reflect.Ptr:
+		*d = ptrDecoder(t, building)
+	case reflect.Interface:
+		*d = interfaceDecoder(t)
 	case reflect.Struct:
+		*d = structDecoder(t, building)
+	case reflect.Map:
+		*d = mapDecoder(t, building)
+	case reflect.Slice:
+		*d = sliceDecoder(t, building)
+	case reflect.Array:
+		*d = arrayDecoder(t, building)
+	case reflect.String:
+		if t == numberType {
+			*d = numberDecoder
+		} else {
+			*d = stringDecoder
+		}
+	case reflect.Bool:
+		*d = boolDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*d = intDecoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		*d = uintDecoder
+	case reflect.Float32, reflect.Float64:
+		*d = floatDecoder
+	default:
+		*d = unsupportedDecoder
+	}
+
+	return *d
+}
+
+func unmarshalerDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	data, e := json.Marshal(src)
+	if e != nil {
+		d.saveError(src, v.Type(), e)
+		return
+	}
+	if e := v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(data); e != nil {
+		d.saveError(src, v.Type(), e)
+	}
+}
+
+func textUnmarshalerDecoder(t reflect.Type) decoder {
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		switch s := src.(type) {
+		case nil:
+		case string:
+			if e := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); e != nil {
+				d.saveError(src, t, e)
+			}
+		default:
+			d.typeError(src, t)
+		}
+	}
+}
+
+func ptrDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	elemDecoder := compileDecoder(t.Elem(), building)
+
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		if src == nil {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		elemDecoder(d, src, v.Elem())
+	}
+}
+
+func interfaceDecoder(t reflect.Type) decoder {
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		if src == nil {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		if t.NumMethod() != 0 {
+			d.typeError(src, t)
+			return
+		}
+		v.Set(reflect.ValueOf(plainJSON(src)))
+	}
+}
+
+// plainJSON returns the unmarshalled JSON value with all numbers as float64,
+// as encoding/json does for values stored in interfaces.
+func plainJSON(src interface{}) interface{} {
+	switch src := src.(type) {
+	case json.Number:
+		f, _ := src.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(src))
+		for i, v := range src {
+			out[i] = plainJSON(v)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(src))
+		for k, v := range src {
+			out[k] = plainJSON(v)
+		}
+		return out
+	default:
+		return src
+	}
+}
+
+// jsonField is a struct field, possibly promoted from an embedded struct, decoded from JSON.
+type jsonField struct {
+	name   string
+	tagged bool
+	index  []int
+	typ    reflect.Type
+	quoted bool
+}
+
+func structDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	type field struct {
+		jsonField
+		dec decoder
+	}
+
+	jsonFields := jsonFields(t)
+	fields := make([]field, len(jsonFields))
+	for i, jf := range jsonFields {
+		fields[i] = field{jsonField: jf, dec: compileDecoder(jf.typ, building)}
+		if jf.quoted {
+			fields[i].dec = quotedDecoder(jf.typ, fields[i].dec)
+		}
+	}
+
+	return func(d *decodeState, src interface{}, v reflect.Value) {
 		srcMap, ok := src.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("t of type struct but src not of type map[string]interface{}")
+			if src != nil {
+				d.typeError(src, t)
+			}
+			return
 		}
-		value, e := parseStruct(srcMap, t)
-		return value, e
-	case reflect.Slice:
-		typeValue := reflect.New(t).Elem()
-		srcSlice, ok := src.([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("type slice expected but got '%s'", reflect.TypeOf(src).Kind())
+
+		// Exact matches are preferred over case-insensitive matches, unknown keys are ignored.
+		// Keys without exact match are only folded if there are any.
+		exact := 0
+		for _, f := range fields {
+			if _, ok := srcMap[f.name]; ok {
+				exact++
+			}
+		}
+		var folded map[string]interface{}
+		if exact < len(srcMap) {
+			folded = make(map[string]interface{}, len(srcMap)-exact)
+			for key, fieldSrc := range srcMap {
+				folded[strings.ToLower(key)] = fieldSrc
+			}
+			for _, f := range fields {
+				if _, ok := srcMap[f.name]; ok {
+					delete(folded, strings.ToLower(f.name))
+				}
+			}
 		}
-		for _, v := range srcSlice {
-			entryValue, e := parseTypeToValue(v, t.Elem())
+
+		// Fields are decoded in order, to report errors in a stable order
+		for i := range fields {
+			f := &fields[i]
+			fieldSrc, ok := srcMap[f.name]
+			if !ok && folded != nil {
+				key := strings.ToLower(f.name)
+				if fieldSrc, ok = folded[key]; ok {
+					delete(folded, key)
+				}
+			}
+			if !ok {
+				continue
+			}
+
+			d.pushKey(f.name)
+			fv, e := fieldByIndexAlloc(v, f.index)
 			if e != nil {
-				return nil, e
+				d.saveError(fieldSrc, f.typ, e)
+			} else {
+				f.dec(d, fieldSrc, fv)
 			}
-			typeValue = reflect.Append(typeValue, *entryValue)
+			d.pop()
 		}
-		return &typeValue, nil
-	default:
-		// JSON marshaller assumes all numbers to be float64 if not defined otherwise.
-		// Here we check if the value is float64 and if so, we convert it to the correct type.
-		//
-		// Currently only support for Int and Float types
-		srcValueFloat, ok := src.(float64)
-		if ok {
-			switch t.Kind() {
-			case reflect.Float64:
-				srcValue := reflect.ValueOf(srcValueFloat)
-				return &srcValue, nil
-			case reflect.Float32:
-				srcValue := reflect.ValueOf(float32(srcValueFloat))
-				return &srcValue, nil
-			case reflect.Int64:
-				srcValue := reflect.ValueOf(int64(srcValueFloat))
-				return &srcValue, nil
-			case reflect.Int32:
-				srcValue := reflect.ValueOf(int32(srcValueFloat))
-				return &srcValue, nil
-			case reflect.Int16:
-				srcValue := reflect.ValueOf(int16(srcValueFloat))
-				return &srcValue, nil
-			case reflect.Int:
-				srcValue := reflect.ValueOf(int(srcValueFloat))
-				return &srcValue, nil
-			default:
-				return nil, fmt.Errorf("Could not parse value %v to type %s", src, t.Kind())
-			}
-		}
-
-		// Check that the src kind matches the type kind
-		if t.Kind() != reflect.Interface && reflect.TypeOf(src).Kind() != t.Kind() {
-			return nil, fmt.Errorf("Could not parse value %v (type: %s) to type %s", src, reflect.TypeOf(src).Kind(), t.Kind())
-		}
-
-		v := reflect.ValueOf(src)
-		return &v, nil
-	}
-}
-
-func parseStruct(src map[string]interface{}, t reflect.Type) (*reflect.Value, error) {
-
-	typeValue := reflect.New(t).Elem()
-
-	// Loop through the struct fields
-	for i := 0; i < t.NumField(); i++ {
-		// Get the struct field
-		field := t.Field(i)
-
-		// Get the value from the source map and check if it is valid.
-		srcValue := reflect.ValueOf(src[field.Tag.Get("json")])
-		if !srcValue.IsValid() {
-			// If it is not valid, ignore it.
-			// Most likely the value was not set in the json
-			// Could possibly add a check to see if omitempty or
-			// some other tag is set the int src
-			continue
+	}
+}
+
+// fieldByIndexAlloc returns the nested field, allocating nil pointers to embedded structs.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
 		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
 
-		// Check if the struct field is a complex field and call the parseType function.
-		// => Recursion.
-		switch srcValue.Kind() {
-		// If struct, convert the src to map[string]interface anf call parseType
-		case reflect.Struct:
-			// No need for interface type assertion check,
-			// as we knwo from teh switch case that conversion is ok
-			respValue, e := parseTypeToValue(srcValue.Interface().(map[string]interface{}), field.Type)
-			if e != nil {
-				return nil, e
+// jsonFields returns the fields encoding/json decodes for the struct type t,
+// applying the Go visibility rules for fields of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	type candidate struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []jsonField
+	next := []candidate{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	// Breadth first search over the embedded structs, one depth level per round
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, c := range current {
+			if visited[c.typ] {
+				continue
 			}
-			typeValue.Field(i).Set(*respValue)
-			continue
+			visited[c.typ] = true
 
-		// If slice, convert the src to []interface and call parseType
-		case reflect.Slice:
-			// No need for interface type assertion check,
-			// as we knwo from teh switch case that conversion is ok
-			respValue, e := parseTypeToValue(srcValue.Interface().([]interface{}), field.Type)
-			if e != nil {
-				return nil, e
+			for i := 0; i < c.typ.NumField(); i++ {
+				sf := c.typ.Field(i)
+				if sf.Anonymous {
+					et := sf.Type
+					if et.Kind() == reflect.Ptr {
+						et = et.Elem()
+					}
+					if sf.PkgPath != "" && et.Kind() != reflect.Struct {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseJSONTag(tag)
+				if !isValidJSONTag(name) {
+					name = ""
+				}
+
+				index := make([]int, len(c.index)+1)
+				copy(index, c.index)
+				index[len(c.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					f := jsonField{
+						name:   name,
+						tagged: name != "",
+						index:  index,
+						typ:    sf.Type,
+						quoted: hasJSONOption(opts, "string") && isQuotable(sf.Type),
+					}
+					if f.name == "" {
+						f.name = sf.Name
+					}
+					fields = append(fields, f)
+					if count[c.typ] > 1 {
+						// Embedded multiple times at the same depth, the duplicate annihilates the field below
+						fields = append(fields, f)
+					}
+					continue
+				}
+
+				// Explore the embedded struct in the next round
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, candidate{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		if fields[i].tagged != fields[j].tagged {
+			return fields[i].tagged
+		}
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	// Remove fields hidden by the Go rules for embedded fields
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
 			}
-			typeValue.Field(i).Set(*respValue)
+		}
+		if advance == 1 {
+			out = append(out, fields[i])
+			continue
+		}
+		// The shallowest field dominates, unless there is a tie not resolved by a tag
+		dominant := fields[i : i+advance]
+		if len(dominant[0].index) == len(dominant[1].index) && dominant[0].tagged == dominant[1].tagged {
 			continue
+		}
+		out = append(out, dominant[0])
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].index, out[j].index)
+	})
+	return out
+}
+
+func lessIndex(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func parseJSONTag(tag string) (string, string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+func hasJSONOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
 
-		// Must be simple type, call parseType directly
+func isValidJSONTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but otherwise any punctuation chars are allowed
+		case !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c > 127):
+			return false
+		}
+	}
+	return true
+}
+
+// isQuotable returns true if the ',string' tag option applies to the type.
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// quotedDecoder decodes values of fields with the ',string' tag option,
+// which are encoded inside a JSON string.
+func quotedDecoder(t reflect.Type, inner decoder) decoder {
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		s, ok := src.(string)
+		if !ok {
+			if src != nil {
+				d.saveError(src, t, fmt.Errorf("invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", t))
+			}
+			return
+		}
+		if s == "null" {
+			return
+		}
+
+		var quoted interface{}
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		if e := dec.Decode(&quoted); e != nil || dec.More() {
+			d.saveError(src, t, fmt.Errorf("invalid use of ,string struct tag, trying to unmarshal %q into %v", s, t))
+			return
+		}
+		switch quoted.(type) {
+		case bool, string, json.Number:
+			inner(d, quoted, v)
 		default:
-			respValue, e := parseTypeToValue(srcValue.Interface(), field.Type)
+			d.saveError(src, t, fmt.Errorf("invalid use of ,string struct tag, trying to unmarshal %q into %v", s, t))
+		}
+	}
+}
+
+func mapDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	kt := t.Key()
+	keyIsText := reflect.PtrTo(kt).Implements(textUnmarshalerType)
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !keyIsText {
+			return unsupportedDecoder
+		}
+	}
+	elemDecoder := compileDecoder(t.Elem(), building)
+
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		srcMap, ok := src.(map[string]interface{})
+		if !ok {
+			if src == nil {
+				v.Set(reflect.Zero(t))
+			} else {
+				d.typeError(src, t)
+			}
+			return
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(srcMap)))
+		}
+		// Keys are sorted to report errors in a stable order
+		keys := make([]string, 0, len(srcMap))
+		for key := range srcMap {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			d.pushKey(key)
+			if kv, ok := decodeMapKey(d, key, kt, keyIsText); ok {
+				elem := reflect.New(t.Elem()).Elem()
+				elemDecoder(d, srcMap[key], elem)
+				v.SetMapIndex(kv, elem)
+			}
+			d.pop()
+		}
+	}
+}
+
+func decodeMapKey(d *decodeState, key string, kt reflect.Type, keyIsText bool) (reflect.Value, bool) {
+	kv := reflect.New(kt).Elem()
+	switch {
+	case keyIsText:
+		if e := kv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); e != nil {
+			d.saveError(key, kt, e)
+			return kv, false
+		}
+	case kt.Kind() == reflect.String:
+		kv.SetString(key)
+	case kv.CanInt():
+		n, e := strconv.ParseInt(key, 10, 64)
+		if e != nil || kv.OverflowInt(n) {
+			d.numberError(key, kt)
+			return kv, false
+		}
+		kv.SetInt(n)
+	default:
+		n, e := strconv.ParseUint(key, 10, 64)
+		if e != nil || kv.OverflowUint(n) {
+			d.numberError(key, kt)
+			return kv, false
+		}
+		kv.SetUint(n)
+	}
+	return kv, true
+}
+
+func sliceDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	elemDecoder := compileDecoder(t.Elem(), building)
+	isBytes := t.Elem().Kind() == reflect.Uint8
+
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		switch src := src.(type) {
+		case nil:
+			v.Set(reflect.Zero(t))
+		case []interface{}:
+			v.Set(reflect.MakeSlice(t, len(src), len(src)))
+			for i, elemSrc := range src {
+				d.pushIndex(i)
+				elemDecoder(d, elemSrc, v.Index(i))
+				d.pop()
+			}
+		case string:
+			// Byte slices are encoded as base64 strings
+			if !isBytes {
+				d.typeError(src, t)
+				return
+			}
+			b, e := base64.StdEncoding.DecodeString(src)
 			if e != nil {
-				return nil, e
+				d.saveError(src, t, e)
+				return
 			}
-			typeValue.Field(i).Set(*respValue)
-			continue
+			v.SetBytes(b)
+		default:
+			d.typeError(src, t)
+		}
+	}
+}
+
+func arrayDecoder(t reflect.Type, building map[reflect.Type]*decoder) decoder {
+	elemDecoder := compileDecoder(t.Elem(), building)
+
+	return func(d *decodeState, src interface{}, v reflect.Value) {
+		srcSlice, ok := src.([]interface{})
+		if !ok {
+			if src != nil {
+				d.typeError(src, t)
+			}
+			return
+		}
+
+		// Additional values are ignored, missing values are zero
+		for i := 0; i < v.Len(); i++ {
+			if i < len(srcSlice) {
+				d.pushIndex(i)
+				elemDecoder(d, srcSlice[i], v.Index(i))
+				d.pop()
+			} else {
+				v.Index(i).Set(reflect.Zero(t.Elem()))
+			}
+		}
+	}
+}
+
+func stringDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case string:
+		v.SetString(src)
+	default:
+		d.typeError(src, v.Type())
+	}
+}
+
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+func numberDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case json.Number:
+		v.SetString(string(src))
+	case float64:
+		v.SetString(formatFloat(src))
+	case string:
+		if !jsonNumberPattern.MatchString(src) {
+			d.saveError(src, v.Type(), fmt.Errorf("invalid number literal %q", src))
+			return
+		}
+		v.SetString(src)
+	default:
+		d.typeError(src, v.Type())
+	}
+}
+
+func boolDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case bool:
+		v.SetBool(src)
+	default:
+		d.typeError(src, v.Type())
+	}
+}
+
+func intDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case json.Number:
+		n, e := strconv.ParseInt(string(src), 10, 64)
+		if e != nil || v.OverflowInt(n) {
+			d.numberError(string(src), v.Type())
+			return
+		}
+		v.SetInt(n)
+	case float64:
+		if src != math.Trunc(src) || src < math.MinInt64 || src >= math.MaxInt64 || v.OverflowInt(int64(src)) {
+			d.numberError(formatFloat(src), v.Type())
+			return
 		}
+		v.SetInt(int64(src))
+	default:
+		d.typeError(src, v.Type())
 	}
+}
 
-	return &typeValue, nil
+func uintDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case json.Number:
+		n, e := strconv.ParseUint(string(src), 10, 64)
+		if e != nil || v.OverflowUint(n) {
+			d.numberError(string(src), v.Type())
+			return
+		}
+		v.SetUint(n)
+	case float64:
+		if src != math.Trunc(src) || src < 0 || src >= math.MaxUint64 || v.OverflowUint(uint64(src)) {
+			d.numberError(formatFloat(src), v.Type())
+			return
+		}
+		v.SetUint(uint64(src))
+	default:
+		d.typeError(src, v.Type())
+	}
+}
+
+func floatDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	switch src := src.(type) {
+	case nil:
+	case json.Number:
+		f, e := strconv.ParseFloat(string(src), v.Type().Bits())
+		if e != nil {
+			d.numberError(string(src), v.Type())
+			return
+		}
+		v.SetFloat(f)
+	case float64:
+		if v.OverflowFloat(src) {
+			d.numberError(formatFloat(src), v.Type())
+			return
+		}
+		v.SetFloat(src)
+	default:
+		d.typeError(src, v.Type())
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func unsupportedDecoder(d *decodeState, src interface{}, v reflect.Value) {
+	if src != nil {
+		d.typeError(src, v.Type())
+	}
 }
@@ -0,0 +1,195 @@
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type convNumbers struct {
+	I   int
+	I8  int8
+	U   uint
+	U8  uint8
+	F32 float32
+	F   float64
+	N   json.Number
+}
+
+type convQuoted struct {
+	Count int     `json:"count,string"`
+	Flag  bool    `json:"flag,string"`
+	Ratio float64 `json:"ratio,string"`
+	Name  string  `json:"name,string"`
+}
+
+type convBase struct {
+	ID  int `json:"id"`
+	Dup string
+}
+
+type ConvExtra struct {
+	Kind string `json:"kind"`
+	Dup  string
+}
+
+type convEmbedded struct {
+	convBase
+	*ConvExtra
+	Name string `json:"name"`
+}
+
+type convKeys struct {
+	UserName string
+	Tagged   int `json:"tagged"`
+}
+
+type convNull struct {
+	I int
+	S string
+	B bool
+	M map[string]int
+	L []int
+	P *int
+	T time.Time
+}
+
+// TestParseTypeConformance compares ParseType and JSONCodec.Decode with json.Unmarshal.
+func TestParseTypeConformance(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   reflect.Type
+		input string
+	}{
+		{"int", reflect.TypeOf(convNumbers{}), `{"I":42,"I8":-128,"U":7,"U8":255}`},
+		{"float", reflect.TypeOf(convNumbers{}), `{"F32":1.5,"F":1e3}`},
+		{"number", reflect.TypeOf(convNumbers{}), `{"N":12.50}`},
+		{"fraction into int", reflect.TypeOf(convNumbers{}), `{"I":1.5}`},
+		{"exponent into int", reflect.TypeOf(convNumbers{}), `{"I":1e2}`},
+		{"negative into uint", reflect.TypeOf(convNumbers{}), `{"U":-1}`},
+		{"string into int", reflect.TypeOf(convNumbers{}), `{"I":"1"}`},
+		{"scalar int", reflect.TypeOf(0), `5`},
+
+		{"overflow int8", reflect.TypeOf(convNumbers{}), `{"I8":128}`},
+		{"overflow uint8", reflect.TypeOf(convNumbers{}), `{"U8":256}`},
+		{"overflow int", reflect.TypeOf(convNumbers{}), `{"I":9223372036854775808}`},
+		{"max uint", reflect.TypeOf(convNumbers{}), `{"U":18446744073709551615}`},
+		{"overflow float32", reflect.TypeOf(convNumbers{}), `{"F32":1e40}`},
+
+		{"string option", reflect.TypeOf(convQuoted{}), `{"count":"12","flag":"true","ratio":"1.5","name":"\"x\""}`},
+		{"string option unquoted", reflect.TypeOf(convQuoted{}), `{"count":12}`},
+		{"string option invalid", reflect.TypeOf(convQuoted{}), `{"count":"x"}`},
+		{"string option bool", reflect.TypeOf(convQuoted{}), `{"flag":"yes"}`},
+		{"string option null", reflect.TypeOf(convQuoted{}), `{"count":null}`},
+
+		{"embedded fields", reflect.TypeOf(convEmbedded{}), `{"id":1,"kind":"k","name":"n"}`},
+		{"embedded conflict", reflect.TypeOf(convEmbedded{}), `{"Dup":"x"}`},
+		{"embedded pointer", reflect.TypeOf(convEmbedded{}), `{"kind":"k"}`},
+
+		{"lower case key", reflect.TypeOf(convKeys{}), `{"username":"a","TAGGED":2}`},
+		{"exact and folded key", reflect.TypeOf(convKeys{}), `{"USERNAME":"a","UserName":"b"}`},
+		{"unknown key", reflect.TypeOf(convKeys{}), `{"user_name":"a"}`},
+
+		{"null fields", reflect.TypeOf(convNull{}), `{"I":null,"S":null,"B":null,"M":null,"L":null,"P":null,"T":null}`},
+		{"null struct", reflect.TypeOf(convNull{}), `null`},
+		{"null int", reflect.TypeOf(0), `null`},
+		{"null slice element", reflect.TypeOf([]int{}), `[1,null,3]`},
+		{"null map value", reflect.TypeOf(map[string]int{}), `{"a":null}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := reflect.New(test.typ)
+			wantErr := json.Unmarshal([]byte(test.input), want.Interface())
+
+			dec := json.NewDecoder(strings.NewReader(test.input))
+			dec.UseNumber()
+			var src interface{}
+			if e := dec.Decode(&src); e != nil {
+				t.Fatal(e)
+			}
+			parsed, parseErr := ParseType(src, test.typ)
+			if (parseErr != nil) != (wantErr != nil) {
+				t.Errorf("ParseType: error %v, json.Unmarshal: error %v", parseErr, wantErr)
+			} else if wantErr == nil && !reflect.DeepEqual(parsed, want.Elem().Interface()) {
+				t.Errorf("ParseType: %+v, json.Unmarshal: %+v", parsed, want.Elem().Interface())
+			}
+
+			decoded := reflect.New(test.typ)
+			decodeErr := (&JSONCodec{}).Decode(strings.NewReader(test.input), decoded.Interface())
+			if (decodeErr != nil) != (wantErr != nil) {
+				t.Errorf("JSONCodec.Decode: error %v, json.Unmarshal: error %v", decodeErr, wantErr)
+			} else if wantErr == nil && !reflect.DeepEqual(decoded.Elem().Interface(), want.Elem().Interface()) {
+				t.Errorf("JSONCodec.Decode: %+v, json.Unmarshal: %+v", decoded.Elem().Interface(), want.Elem().Interface())
+			}
+		})
+	}
+}
+
+type convPrice struct {
+	Price float64 `json:"price"`
+	Qty   uint8   `json:"qty"`
+}
+
+type convOrder struct {
+	Items   []convPrice          `json:"items"`
+	Prices  map[string]convPrice `json:"prices"`
+	Stock   map[int]int          `json:"stock"`
+	Matrix  [][2]int             `json:"matrix"`
+	Comment *string              `json:"comment"`
+}
+
+func TestDecodeErrorPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errors []FieldError
+	}{
+		{"slice element field", `{"items":[{},{},{},{"price":"1.5"}]}`, []FieldError{
+			{Field: "items[3].price", Expected: "float64", Received: "string"},
+		}},
+		{"map value field", `{"prices":{"apple":{"qty":300},"pear":{"price":true}}}`, []FieldError{
+			{Field: "prices.apple.qty", Expected: "uint8", Received: "number"},
+			{Field: "prices.pear.price", Expected: "float64", Received: "bool"},
+		}},
+		{"map key", `{"stock":{"1":2,"x":3}}`, []FieldError{
+			{Field: "stock.x", Expected: "int", Received: "number"},
+		}},
+		{"map value", `{"stock":{"7":"many"}}`, []FieldError{
+			{Field: "stock.7", Expected: "int", Received: "string"},
+		}},
+		{"nested arrays", `{"matrix":[[1,2],[3,"4"]]}`, []FieldError{
+			{Field: "matrix[1][1]", Expected: "int", Received: "string"},
+		}},
+		{"pointer", `{"comment":1}`, []FieldError{
+			{Field: "comment", Expected: "string", Received: "number"},
+		}},
+		{"root", `[]`, []FieldError{
+			{Field: "", Expected: "rest.convOrder", Received: "array"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var order convOrder
+			e := (&JSONCodec{}).Decode(strings.NewReader(test.input), &order)
+			de, ok := e.(*DecodeError)
+			if !ok {
+				t.Fatalf("error %v, want *DecodeError", e)
+			}
+			if len(de.Errors) != len(test.errors) {
+				t.Fatalf("errors %+v, want %+v", de.Errors, test.errors)
+			}
+			for i, fe := range de.Errors {
+				want := test.errors[i]
+				if fe.Field != want.Field || fe.Expected != want.Expected || fe.Received != want.Received || fe.Reason == "" {
+					t.Errorf("error %+v, want %+v", fe, want)
+				}
+			}
+			if de.HTTPStatus() != 400 {
+				t.Errorf("status %d", de.HTTPStatus())
+			}
+		})
+	}
+}
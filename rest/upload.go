@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for files whose extension does not match the detected content type, see MultipartForm.OnMismatch.
+const (
+	MismatchIgnore = "ignore"
+	MismatchReject = "reject"
+)
+
+// FileScanner checks uploaded files before they are passed to module methods, e.g. for malware.
+//
+// Returning an error rejects the request. Errors created with NewError or implementing
+// HTTPStatusCoder set the status of the response, all other errors respond with an internal error.
+type FileScanner interface {
+	Scan(ctx context.Context, fi *FileInfo) error
+}
+
+// FileScannerFunc is a function implementing FileScanner.
+type FileScannerFunc func(ctx context.Context, fi *FileInfo) error
+
+func (f FileScannerFunc) Scan(ctx context.Context, fi *FileInfo) error {
+	return f(ctx, fi)
+}
+
+// AddFileScanner adds a scanner that checks every uploaded file, in the order the scanners are added.
+// Must be called before the routes are set up.
+func (c *Controller) AddFileScanner(s FileScanner) {
+	c.fileScanners = append(c.fileScanners, s)
+}
+
+// fileRules are the compiled upload rules of a file form field.
+type fileRules struct {
+	types          []string
+	extensions     []string
+	rejectMismatch bool
+}
+
+func newFileRules(form MultipartForm) (*fileRules, error) {
+	rules := &fileRules{}
+
+	for _, t := range form.AllowedTypes {
+		mediaType, _, e := mime.ParseMediaType(t)
+		if e != nil {
+			return nil, fmt.Errorf("form '%s': invalid allowed type '%s'", form.Name, t)
+		}
+		rules.types = append(rules.types, mediaType)
+	}
+
+	for _, ext := range form.AllowedExtensions {
+		rules.extensions = append(rules.extensions, "."+strings.TrimPrefix(strings.ToLower(ext), "."))
+	}
+
+	switch form.OnMismatch {
+	case "", MismatchIgnore:
+	case MismatchReject:
+		rules.rejectMismatch = true
+	default:
+		return nil, fmt.Errorf("form '%s': invalid mismatch policy '%s'", form.Name, form.OnMismatch)
+	}
+	return rules, nil
+}
+
+// checkName checks the extension of the file name, before the content is read.
+func (r *fileRules) checkName(fileName string) error {
+	if r.extensions == nil {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, allowed := range r.extensions {
+		if ext == allowed {
+			return nil
+		}
+	}
+	return BadRequest("file '%s' has a not allowed extension, allowed are: %s",
+		fileName, strings.Join(r.extensions, ", "))
+}
+
+// checkContent checks the content type detected from the first bytes of the file.
+func (r *fileRules) checkContent(fi *FileInfo) error {
+	detected, _, _ := mime.ParseMediaType(fi.ContentType)
+
+	if r.types != nil && !matchesAnyType(detected, r.types) {
+		return NewError(http.StatusUnsupportedMediaType, "file '%s' has type '%s', allowed are: %s",
+			fi.FileName, detected, strings.Join(r.types, ", "))
+	}
+
+	if r.rejectMismatch {
+		ext := strings.ToLower(filepath.Ext(fi.FileName))
+		expected, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+		if expected != "" && !compatibleTypes(expected, detected) {
+			return BadRequest("file '%s' has extension '%s' but content of type '%s'", fi.FileName, ext, detected)
+		}
+	}
+	return nil
+}
+
+// matchesAnyType returns true if the media type matches one of the types, which may be wildcards like 'image/*'.
+func matchesAnyType(mediaType string, types []string) bool {
+	for _, t := range types {
+		if matchRange(t, mediaType) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compatibleTypes returns true if the detected content type does not contradict the type of the extension.
+// Content detection can not tell binary formats from 'application/octet-stream'
+// or text formats from 'text/plain', so these are compatible with any binary or text type.
+func compatibleTypes(expected, detected string) bool {
+	switch detected {
+	case expected, "application/octet-stream":
+		return true
+	case "text/plain":
+		return strings.HasPrefix(expected, "text/") ||
+			strings.Contains(expected, "json") || strings.Contains(expected, "xml") ||
+			strings.Contains(expected, "javascript")
+	}
+	return false
+}
+
+// scanFile runs the file scanners on the file.
+func scanFile(ctx context.Context, scanners []FileScanner, fi *FileInfo) error {
+	for _, s := range scanners {
+		if e := s.Scan(ctx, fi); e != nil {
+			var coder HTTPStatusCoder
+			if errors.As(e, &coder) {
+				return e
+			}
+			return fmt.Errorf("scanning file '%s': %v", fi.FileName, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	pngContent = "\x89PNG\r\n\x1a\n0000"
+	pdfContent = "%PDF-1.4 0000"
+)
+
+type uploadFile struct {
+	name    string
+	content string
+}
+
+func fileRequest(t *testing.T, path, field string, files ...uploadFile) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range files {
+		w, e := mw.CreateFormFile(field, f.name)
+		if e != nil {
+			t.Fatal(e)
+		}
+		io.WriteString(w, f.content)
+	}
+	if e := mw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	r := httptest.NewRequest("POST", path, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+type uploadModule struct {
+	paths []string
+}
+
+func (m *uploadModule) Upload(fi *FileInfo) (string, error) {
+	m.paths = append(m.paths, fi.Path)
+	return fmt.Sprintf("%s %s %d", fi.FileName, fi.ContentType, fi.FileSize), nil
+}
+
+func (m *uploadModule) UploadMany(files []*FileInfo) (int, error) {
+	return len(files), nil
+}
+
+func uploadController(m *uploadModule) *Controller {
+	c := NewController()
+	c.AddModule(m)
+	file := func(name string, form MultipartForm) Request {
+		form.Name, form.IsFile = "file", true
+		return Request{Name: name, Func: "Upload", Method: "POST", URI: "/" + name, Body: MultipartBody(form)}
+	}
+	c.Requests = []Request{
+		file("images", MultipartForm{AllowedTypes: []string{"image/*"}, AllowedExtensions: []string{"png", ".JPG"}}),
+		file("pdf", MultipartForm{AllowedTypes: []string{"application/pdf"}}),
+		file("strict", MultipartForm{OnMismatch: MismatchReject}),
+		file("lenient", MultipartForm{}),
+		{Name: "many", Func: "UploadMany", Method: "POST", URI: "/many", Body: BodyType{
+			IsMultipart:  true,
+			Forms:        []MultipartForm{{Name: "file", IsFile: true, Multiple: true}},
+			MaxFileSize:  10,
+			MaxTotalSize: 25,
+			MaxFiles:     3,
+		}},
+	}
+	return c
+}
+
+func TestUploadRules(t *testing.T) {
+	m := &uploadModule{}
+	h := uploadController(m).Routes()
+
+	tests := []struct {
+		name   string
+		path   string
+		files  []uploadFile
+		status int
+	}{
+		{"allowed type and extension", "/images", []uploadFile{{"a.png", pngContent}}, http.StatusOK},
+		{"extension case", "/images", []uploadFile{{"A.PNG", pngContent}}, http.StatusOK},
+		{"type not allowed", "/images", []uploadFile{{"a.png", "hello"}}, http.StatusUnsupportedMediaType},
+		{"extension not allowed", "/images", []uploadFile{{"a.gif", pngContent}}, http.StatusBadRequest},
+		{"exact type", "/pdf", []uploadFile{{"a.pdf", pdfContent}}, http.StatusOK},
+		{"exact type not allowed", "/pdf", []uploadFile{{"a.pdf", pngContent}}, http.StatusUnsupportedMediaType},
+		{"reject mismatch", "/strict", []uploadFile{{"a.pdf", pngContent}}, http.StatusBadRequest},
+		{"reject matching", "/strict", []uploadFile{{"a.png", pngContent}}, http.StatusOK},
+		{"reject compatible text", "/strict", []uploadFile{{"a.json", `{"a":1}`}}, http.StatusOK},
+		{"reject unknown extension", "/strict", []uploadFile{{"a.unknown-ext", pngContent}}, http.StatusOK},
+		{"ignore mismatch", "/lenient", []uploadFile{{"a.pdf", pngContent}}, http.StatusOK},
+		{"files within limits", "/many", []uploadFile{{"a", "12345678"}, {"b", "12345678"}, {"c", "12345678"}}, http.StatusOK},
+		{"file too large", "/many", []uploadFile{{"a", "12345678901"}}, http.StatusRequestEntityTooLarge},
+		{"form too large", "/many", []uploadFile{{"a", "1234567890"}, {"b", "1234567890"}, {"c", "123456"}}, http.StatusRequestEntityTooLarge},
+		{"too many files", "/many", []uploadFile{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, fileRequest(t, test.path, "file", test.files...))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d, body %s", test.name, w.Code, test.status, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, fileRequest(t, "/images", "file", uploadFile{"a.png", pngContent}))
+	if want := "\"a.png image/png 12\"\n"; w.Body.String() != want {
+		t.Errorf("body %s, want %s", w.Body.String(), want)
+	}
+	for _, path := range m.paths {
+		if _, e := os.Stat(path); !os.IsNotExist(e) {
+			t.Errorf("temporary file %s not removed", path)
+		}
+	}
+}
+
+func TestUploadScanner(t *testing.T) {
+	errScanner := errors.New("scanner unavailable")
+	var scanned []string
+	c := uploadController(&uploadModule{})
+	c.AddFileScanner(FileScannerFunc(func(ctx context.Context, fi *FileInfo) error {
+		f, e := fi.Open()
+		if e != nil {
+			return e
+		}
+		defer f.Close()
+		content, e := io.ReadAll(f)
+		if e != nil {
+			return e
+		}
+		scanned = append(scanned, fi.FileName+" "+fi.SHA256[:8])
+
+		switch {
+		case strings.Contains(string(content), "virus"):
+			return NewError(http.StatusUnprocessableEntity, "file '%s' is infected", fi.FileName)
+		case strings.Contains(string(content), "offline"):
+			return errScanner
+		}
+		return nil
+	}))
+	h := c.Routes()
+
+	tests := []struct {
+		content string
+		status  int
+	}{
+		{"clean", http.StatusOK},
+		{"virus", http.StatusUnprocessableEntity},
+		{"offline", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, fileRequest(t, "/lenient", "file", uploadFile{"a.txt", test.content}))
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.content, w.Code, test.status)
+		}
+		if strings.Contains(w.Body.String(), errScanner.Error()) {
+			t.Errorf("%s: scanner error sent to the client", test.content)
+		}
+	}
+	sum := sha256.Sum256([]byte("clean"))
+	if len(scanned) != 3 || scanned[0] != "a.txt "+hex.EncodeToString(sum[:4]) {
+		t.Errorf("scanned %v", scanned)
+	}
+}
+
+func TestUploadRulesConfig(t *testing.T) {
+	tests := map[string]MultipartForm{
+		"invalid mismatch policy": {Name: "file", IsFile: true, OnMismatch: "warn"},
+		"invalid allowed type":    {Name: "file", IsFile: true, AllowedTypes: []string{"image/png;;"}},
+		"rules without file":      {Name: "title", AllowedExtensions: []string{"png"}},
+	}
+	for name, form := range tests {
+		c := NewController()
+		c.AddModule(&uploadModule{})
+		c.Requests = []Request{{Name: "upload", Func: "Upload", Method: "POST", URI: "/upload", Body: MultipartBody(form)}}
+		var ce *ConfigError
+		if e := c.Validate(); !errors.As(e, &ce) {
+			t.Errorf("%s: error %v, want *ConfigError", name, e)
+		}
+	}
+}
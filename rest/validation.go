@@ -0,0 +1,519 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidatorFunc checks a single value against a custom validation rule.
+// param is the text after '=' in the rule, e.g. 'x' for 'myrule=x'.
+// The message of the returned error is reported as reason to the client.
+type ValidatorFunc func(value interface{}, param string) error
+
+var validatorRegistry = make(map[string]ValidatorFunc)
+
+// AddValidator adds a custom validation rule that can be used in 'validate' struct tags
+// and in the route configuration.
+// Must be called before the routes are set up.
+func AddValidator(name string, fn ValidatorFunc) {
+	validatorRegistry[name] = fn
+}
+
+// rule is a compiled validation rule. check returns the reason if the value is invalid.
+type rule struct {
+	name  string
+	check func(v reflect.Value) string
+}
+
+// validateState holds the path of the value being validated and all violations found.
+type validateState struct {
+	decodeState
+}
+
+func (s *validateState) violation(ruleName, reason string) {
+	s.errors = append(s.errors, FieldError{
+		Field:  s.pathString(),
+		Reason: reason,
+		Rule:   ruleName,
+	})
+}
+
+// validator checks the value v and records violations in the state.
+type validator func(s *validateState, v reflect.Value)
+
+// validationError returns the 422 error for the violations, or nil.
+func validationError(fields []FieldError) error {
+	if fields == nil {
+		return nil
+	}
+	return Validation("validation failed", fields...)
+}
+
+// validateValue runs the validator on v and returns the violations.
+func validateValue(val validator, v reflect.Value) []FieldError {
+	if val == nil {
+		return nil
+	}
+	s := &validateState{}
+	val(s, v)
+	return s.errors
+}
+
+// hasRule returns true if the comma separated rules contain the rule, with or without parameter.
+func hasRule(rules, name string) bool {
+	for _, r := range strings.Split(rules, ",") {
+		r = strings.TrimSpace(r)
+		if i := strings.IndexByte(r, '='); i >= 0 {
+			r = r[:i]
+		}
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRules parses a comma separated list of rules, e.g. 'required,min=3', for values of type t.
+func compileRules(rules string, t reflect.Type) ([]rule, error) {
+	compiled := []rule{}
+	for _, r := range strings.Split(rules, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		name, param := r, ""
+		if i := strings.IndexByte(r, '='); i >= 0 {
+			name, param = r[:i], r[i+1:]
+		}
+
+		check, e := compileRule(name, param, t)
+		if e != nil {
+			return nil, fmt.Errorf("rule '%s': %v", r, e)
+		}
+		compiled = append(compiled, rule{name: name, check: check})
+	}
+	return compiled, nil
+}
+
+func compileRule(name, param string, t reflect.Type) (func(v reflect.Value) string, error) {
+	if fn, ok := validatorRegistry[name]; ok {
+		return func(v reflect.Value) string {
+			if e := fn(v.Interface(), param); e != nil {
+				return e.Error()
+			}
+			return ""
+		}, nil
+	}
+
+	elem := t
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	switch name {
+	case "required", "omitempty":
+		// handled by rulesValidator
+		return nil, nil
+	case "min", "max", "len":
+		limit, e := strconv.ParseFloat(param, 64)
+		if e != nil {
+			return nil, fmt.Errorf("invalid number '%s'", param)
+		}
+		return sizeRule(name, limit, elem)
+	case "oneof":
+		return oneOfRule(strings.Fields(param), elem)
+	case "email":
+		return stringRule(elem, "must be a valid email address", func(s string) bool {
+			a, e := mail.ParseAddress(s)
+			return e == nil && a.Address == s
+		})
+	case "url":
+		return stringRule(elem, "must be a valid URL", func(s string) bool {
+			u, e := url.ParseRequestURI(s)
+			return e == nil && u.Scheme != "" && u.Host != ""
+		})
+	case "uuid":
+		return stringRule(elem, "must be a valid UUID", uuidPattern.MatchString)
+	default:
+		return nil, fmt.Errorf("unknown validation rule '%s'", name)
+	}
+}
+
+func sizeRule(name string, limit float64, t reflect.Type) (func(v reflect.Value) string, error) {
+	var size func(v reflect.Value) float64
+	var unit string
+
+	switch t.Kind() {
+	case reflect.String:
+		size = func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = func(v reflect.Value) float64 { return float64(v.Len()) }
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = func(v reflect.Value) float64 { return float64(v.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		size = func(v reflect.Value) float64 { return float64(v.Uint()) }
+	case reflect.Float32, reflect.Float64:
+		size = func(v reflect.Value) float64 { return v.Float() }
+	default:
+		return nil, fmt.Errorf("not applicable to type %s", t)
+	}
+
+	limitText := strconv.FormatFloat(limit, 'g', -1, 64)
+	switch name {
+	case "min":
+		return func(v reflect.Value) string {
+			if size(v) < limit {
+				return "must be at least " + limitText + unit
+			}
+			return ""
+		}, nil
+	case "max":
+		return func(v reflect.Value) string {
+			if size(v) > limit {
+				return "must be at most " + limitText + unit
+			}
+			return ""
+		}, nil
+	default:
+		if unit == "" {
+			return nil, fmt.Errorf("not applicable to type %s", t)
+		}
+		return func(v reflect.Value) string {
+			if size(v) != limit {
+				return "must have exactly " + limitText + unit
+			}
+			return ""
+		}, nil
+	}
+}
+
+func oneOfRule(values []string, t reflect.Type) (func(v reflect.Value) string, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no values")
+	}
+	allowed := make(map[string]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+	reason := "must be one of " + strings.Join(values, ", ")
+
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) string {
+			if !allowed[fmt.Sprint(v.Interface())] {
+				return reason
+			}
+			return ""
+		}, nil
+	default:
+		return nil, fmt.Errorf("not applicable to type %s", t)
+	}
+}
+
+func stringRule(t reflect.Type, reason string, valid func(s string) bool) (func(v reflect.Value) string, error) {
+	if t.Kind() != reflect.String {
+		return nil, fmt.Errorf("not applicable to type %s", t)
+	}
+	return func(v reflect.Value) string {
+		if !valid(v.String()) {
+			return reason
+		}
+		return ""
+	}, nil
+}
+
+// rulesValidator returns the validator applying the rules to a value.
+//
+// 'required' rejects zero values, nil pointers and empty slices and maps.
+// 'omitempty' skips all other rules for zero values. Nil pointers always skip the other rules.
+func rulesValidator(rules string, t reflect.Type) (validator, error) {
+	compiled, e := compileRules(rules, t)
+	if e != nil {
+		return nil, e
+	}
+	if len(compiled) == 0 {
+		return nil, nil
+	}
+
+	required, omitEmpty := false, false
+	checks := compiled[:0]
+	for _, r := range compiled {
+		switch {
+		case r.name == "required":
+			required = true
+		case r.name == "omitempty":
+			omitEmpty = true
+		default:
+			checks = append(checks, r)
+		}
+	}
+
+	return func(s *validateState, v reflect.Value) {
+		if isEmptyValue(v) {
+			if required {
+				s.violation("required", "is required")
+				return
+			}
+			if omitEmpty {
+				return
+			}
+		}
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		for _, r := range checks {
+			if reason := r.check(v); reason != "" {
+				s.violation(r.name, reason)
+			}
+		}
+	}, nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// validatorCache holds the validator built from the 'validate' struct tags of every type, nil if none.
+var validatorCache sync.Map // map[reflect.Type]validator
+
+// validatorFor returns the validator of the 'validate' struct tags of the type t and its nested types.
+// The validator is nil if the type has no rules.
+func validatorFor(t reflect.Type) (validator, error) {
+	if v, ok := validatorCache.Load(t); ok {
+		return v.(validator), nil
+	}
+	v, e := compileValidator(t, make(map[reflect.Type]*validator))
+	if e != nil {
+		return nil, e
+	}
+	validatorCache.Store(t, v)
+	return v, nil
+}
+
+func compileValidator(t reflect.Type, building map[reflect.Type]*validator) (validator, error) {
+	if v, ok := validatorCache.Load(t); ok {
+		return v.(validator), nil
+	}
+	if v, ok := building[t]; ok {
+		return func(s *validateState, rv reflect.Value) {
+			if *v != nil {
+				(*v)(s, rv)
+			}
+		}, nil
+	}
+	v := new(validator)
+	building[t] = v
+
+	var e error
+	switch t.Kind() {
+	case reflect.Ptr:
+		var elem validator
+		if elem, e = compileValidator(t.Elem(), building); elem != nil {
+			*v = func(s *validateState, rv reflect.Value) {
+				if !rv.IsNil() {
+					elem(s, rv.Elem())
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		var elem validator
+		if elem, e = compileValidator(t.Elem(), building); elem != nil {
+			*v = func(s *validateState, rv reflect.Value) {
+				for i := 0; i < rv.Len(); i++ {
+					s.pushIndex(i)
+					elem(s, rv.Index(i))
+					s.pop()
+				}
+			}
+		}
+	case reflect.Map:
+		var elem validator
+		if elem, e = compileValidator(t.Elem(), building); elem != nil {
+			*v = func(s *validateState, rv reflect.Value) {
+				iter := rv.MapRange()
+				for iter.Next() {
+					s.pushKey(fmt.Sprint(iter.Key().Interface()))
+					elem(s, iter.Value())
+					s.pop()
+				}
+			}
+		}
+	case reflect.Struct:
+		if t != timeType {
+			*v, e = structValidator(t, building)
+		}
+	}
+	if e != nil {
+		return nil, e
+	}
+	return *v, nil
+}
+
+func structValidator(t reflect.Type, building map[reflect.Type]*validator) (validator, error) {
+	type field struct {
+		name  string
+		index []int
+		rules validator
+		inner validator
+	}
+
+	fields := []field{}
+	for _, jf := range jsonFields(t) {
+		sf := t.FieldByIndex(jf.index)
+		rules, e := rulesValidator(sf.Tag.Get("validate"), jf.typ)
+		if e != nil {
+			return nil, fmt.Errorf("field '%s' of %s: %v", sf.Name, t, e)
+		}
+		inner, e := compileValidator(jf.typ, building)
+		if e != nil {
+			return nil, e
+		}
+		if rules != nil || inner != nil {
+			fields = append(fields, field{name: jf.name, index: jf.index, rules: rules, inner: inner})
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return func(s *validateState, v reflect.Value) {
+		for _, f := range fields {
+			fv, e := v.FieldByIndexErr(f.index)
+			if e != nil {
+				// nil embedded pointer
+				continue
+			}
+			s.pushKey(f.name)
+			if f.rules != nil {
+				f.rules(s, fv)
+			}
+			if f.inner != nil {
+				f.inner(s, fv)
+			}
+			s.pop()
+		}
+	}, nil
+}
+
+// pathValidator returns the validator applying the rules to the values at the path
+// of JSON field names, e.g. 'sender.name'. Slices and arrays on the path apply the rules to every element.
+func pathValidator(t reflect.Type, path []string, rules string) (validator, error) {
+	if len(path) == 0 {
+		return rulesValidator(rules, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner, e := pathValidator(t.Elem(), path, rules)
+		if e != nil || inner == nil {
+			return inner, e
+		}
+		return func(s *validateState, v reflect.Value) {
+			if !v.IsNil() {
+				inner(s, v.Elem())
+			}
+		}, nil
+	case reflect.Slice, reflect.Array:
+		inner, e := pathValidator(t.Elem(), path, rules)
+		if e != nil || inner == nil {
+			return inner, e
+		}
+		return func(s *validateState, v reflect.Value) {
+			for i := 0; i < v.Len(); i++ {
+				s.pushIndex(i)
+				inner(s, v.Index(i))
+				s.pop()
+			}
+		}, nil
+	case reflect.Struct:
+		for _, jf := range jsonFields(t) {
+			if jf.name != path[0] {
+				continue
+			}
+			inner, e := pathValidator(jf.typ, path[1:], rules)
+			if e != nil || inner == nil {
+				return inner, e
+			}
+			return func(s *validateState, v reflect.Value) {
+				fv, e := v.FieldByIndexErr(jf.index)
+				if e != nil {
+					return
+				}
+				s.pushKey(jf.name)
+				inner(s, fv)
+				s.pop()
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("field '%s' not found in %s", path[0], t)
+}
+
+// bodyValidator combines the struct tag rules of the type t with the rules of the route configuration,
+// which map a path of JSON field names to rules.
+func bodyValidator(t reflect.Type, routeRules map[string]string) (validator, error) {
+	validators := []validator{}
+
+	v, e := validatorFor(t)
+	if e != nil {
+		return nil, e
+	}
+	if v != nil {
+		validators = append(validators, v)
+	}
+
+	paths := make([]string, 0, len(routeRules))
+	for path := range routeRules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		v, e := pathValidator(t, strings.Split(path, "."), routeRules[path])
+		if e != nil {
+			return nil, fmt.Errorf("validation of '%s': %v", path, e)
+		}
+		if v != nil {
+			validators = append(validators, v)
+		}
+	}
+
+	switch len(validators) {
+	case 0:
+		return nil, nil
+	case 1:
+		return validators[0], nil
+	default:
+		return func(s *validateState, v reflect.Value) {
+			for _, val := range validators {
+				val(s, v)
+			}
+		}, nil
+	}
+}
+
+// isValidationError returns true if the error lists violations of validation rules,
+// to continue binding and report the violations of all arguments together.
+func isValidationError(e error) (*Error, bool) {
+	restErr, ok := e.(*Error)
+	return restErr, ok && restErr.Status == http.StatusUnprocessableEntity && restErr.Fields != nil
+}
@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationRules(t *testing.T) {
+	AddValidator("even", func(value interface{}, param string) error {
+		if value.(int)%2 != 0 {
+			return fmt.Errorf("must be even")
+		}
+		return nil
+	})
+	name := "x"
+
+	tests := []struct {
+		rules string
+		value interface{}
+		valid bool
+	}{
+		{"required", "a", true},
+		{"required", "", false},
+		{"required", 0, false},
+		{"required", []int(nil), false},
+		{"required", []int{}, false},
+		{"required", map[string]int{}, false},
+		{"required", (*string)(nil), false},
+		{"required", &name, true},
+		{"omitempty,min=3", "", true},
+		{"omitempty,min=3", "ab", false},
+		{"min=3", "abc", true},
+		{"min=3", "äb", false},
+		{"min=3", 2, false},
+		{"min=1.5", 1.5, true},
+		{"min=2", []int{1}, false},
+		{"max=3", "abcd", false},
+		{"max=3", uint(3), true},
+		{"max=2", map[string]int{"a": 1, "b": 2, "c": 3}, false},
+		{"len=2", "ab", true},
+		{"len=2", []string{"a"}, false},
+		{"oneof=red green", "green", true},
+		{"oneof=red green", "blue", false},
+		{"oneof=1 2", 3, false},
+		{"email", "jane@example.com", true},
+		{"email", "Jane <jane@example.com>", false},
+		{"url", "https://example.com/a", true},
+		{"url", "example.com", false},
+		{"uuid", "3f2504e0-4f89-11d3-9a0c-0305e82c3301", true},
+		{"uuid", "3f2504e0", false},
+		{"min=3", (*string)(nil), true},
+		{"min=1", &name, true},
+		{"even", 4, true},
+		{"even", 3, false},
+		{"required,min=2,max=4", "abc", true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %#v", test.rules, test.value), func(t *testing.T) {
+			v := reflect.ValueOf(test.value)
+			val, e := rulesValidator(test.rules, v.Type())
+			if e != nil {
+				t.Fatal(e)
+			}
+			violations := validateValue(val, v)
+			if (len(violations) == 0) != test.valid {
+				t.Errorf("violations %+v, want valid %v", violations, test.valid)
+			}
+		})
+	}
+}
+
+type ruleMessage struct {
+	Text string `json:"text" validate:"min=x"`
+}
+
+type ruleModule struct{}
+
+func (ruleModule) Post(m ruleMessage) (string, error) { return m.Text, nil }
+
+func TestValidationInvalidRules(t *testing.T) {
+	tests := map[string]BodyType{
+		"unknown rule in route": {IsJSON: true, JSONStructName: TypeName[orderLine](), Validate: map[string]string{"price": "bogus"}},
+		"unknown path in route": {IsJSON: true, JSONStructName: TypeName[orderLine](), Validate: map[string]string{"cost": "min=1"}},
+		"rule not applicable":   {IsJSON: true, JSONStructName: TypeName[orderLine](), Validate: map[string]string{"sku": "email"}},
+		"invalid tag parameter": {IsJSON: true, JSONStructName: TypeName[ruleMessage]()},
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewController()
+			c.AddModule(ruleModule{})
+			c.AddModule(validationModule{})
+			fn := "Post"
+			if body.JSONStructName != TypeName[ruleMessage]() {
+				fn = "AddLine"
+			}
+			c.Requests = []Request{{Name: name, Func: fn, Method: "POST", URI: "/x", Body: body}}
+
+			var ce *ConfigError
+			if e := c.Validate(); !errors.As(e, &ce) {
+				t.Errorf("error %v, want *ConfigError", e)
+			}
+		})
+	}
+}
+
+type orderLine struct {
+	SKU   int     `json:"sku"`
+	Price float64 `json:"price" validate:"min=0.01"`
+}
+
+type orderCustomer struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email"`
+}
+
+type validatedOrder struct {
+	Customer *orderCustomer       `json:"customer" validate:"required"`
+	Lines    []orderLine          `json:"lines" validate:"min=1"`
+	Extra    map[string]orderLine `json:"extra"`
+}
+
+type validationModule struct{}
+
+func (validationModule) Create(limit int, o validatedOrder) (int, error) { return len(o.Lines), nil }
+func (validationModule) AddLine(l orderLine) (int, error)                { return l.SKU, nil }
+
+func TestValidationFieldPaths(t *testing.T) {
+	c := NewController()
+	c.AddModule(validationModule{})
+	c.Requests = []Request{{
+		Name: "create", Func: "Create", Method: "POST", URI: "/orders",
+		Query: []QueryParam{{Name: "limit", Type: Int, Validate: "max=10"}},
+		Body: BodyType{
+			IsJSON:         true,
+			JSONStructName: TypeName[validatedOrder](),
+			Validate:       map[string]string{"customer.email": "omitempty,email", "lines.sku": "min=1"},
+		},
+	}}
+	h := c.Routes()
+
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		fields []string
+	}{
+		{"valid", "limit=1", `{"customer":{"name":"a","email":"a@example.com"},"lines":[{"sku":1,"price":1}]}`, nil},
+		{"missing struct", "limit=1", `{"lines":[{"sku":1,"price":1}]}`, []string{"customer"}},
+		{"nested field", "limit=1", `{"customer":{"email":"x"},"lines":[{"sku":1,"price":1}]}`,
+			[]string{"customer.name", "customer.email"}},
+		{"slice elements", "limit=1", `{"customer":{"name":"a"},"lines":[{"sku":1,"price":1},{"sku":0,"price":0}]}`,
+			[]string{"lines[1].price", "lines[1].sku"}},
+		{"map values", "limit=1", `{"customer":{"name":"a"},"lines":[{"sku":1,"price":1}],"extra":{"gift":{"price":0}}}`,
+			[]string{"extra.gift.price"}},
+		{"empty slice", "limit=1", `{"customer":{"name":"a"},"lines":[]}`, []string{"lines"}},
+		{"query and body", "limit=11", `{"customer":{"name":""},"lines":[]}`,
+			[]string{"limit", "customer.name", "lines"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/orders?"+test.query, strings.NewReader(test.body))
+			r.Header.Set("Content-Type", MediaTypeJSON)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if test.fields == nil {
+				if w.Code != http.StatusOK {
+					t.Errorf("status %d, body %s", w.Code, w.Body.String())
+				}
+				return
+			}
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+			}
+			var problem Problem
+			if e := json.Unmarshal(w.Body.Bytes(), &problem); e != nil {
+				t.Fatal(e)
+			}
+			fields := make([]string, len(problem.Errors))
+			for i, fe := range problem.Errors {
+				fields[i] = fe.Field
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("fields %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}